@@ -3,6 +3,7 @@ package fig
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -24,25 +25,228 @@ const (
 	TypeObject
 	TypeEqual
 	TypeCall
+	TypeMacro
+	TypeComment
+	TypeExpr
+	TypeFunc
 )
 
 type Node interface {
 	fmt.Stringer
 	Type() NodeType
+	Pos() Position
+	End() Position
 	clone() Node
 }
 
+type ObjectNode interface {
+	Node
+	Key() string
+	Tags() []string
+	Children() []Node
+	Functions() []FunctionNode
+}
+
+type OptionNode interface {
+	Node
+	Key() string
+	Expr() Node
+}
+
+type ArrayNode interface {
+	Node
+	Elements() []Node
+}
+
+type LiteralNode interface {
+	Node
+	Argument
+	Get() (interface{}, error)
+	Raw() string
+	Unit() string
+}
+
+type TemplateNode interface {
+	Node
+	Parts() []Node
+}
+
+type VariableNode interface {
+	Node
+	Name() string
+	IsLocal() bool
+}
+
+type SliceNode interface {
+	Node
+	Target() Node
+	From() int
+	To() int
+	IsIndex() bool
+	IsCopy() bool
+}
+
+// CallNode is also implemented by MacroNode.
+type CallNode interface {
+	Node
+	Func() string
+	Arguments() []Node
+	Keys() []string
+	Keyword(string) Node
+}
+
+type MacroNode interface {
+	CallNode
+	Block() Node
+}
+
+type FunctionNode interface {
+	Node
+	Func() string
+	Parameters() []Parameter
+	Expr() Node
+}
+
+type UnaryNode interface {
+	Node
+	Operator() string
+	Operand() Node
+}
+
+type BinaryNode interface {
+	Node
+	Operator() string
+	Operands() (Node, Node)
+}
+
+type TernaryNode interface {
+	Node
+	Branches() (Node, Node, Node)
+}
+
+type CommentNode interface {
+	Node
+	Lines() []string
+}
+
 type note struct {
-	Token Token
+	Tokens []Token
+}
+
+func createNote() *note {
+	var n note
+	return &n
+}
+
+func (n *note) String() string {
+	var str []string
+	for _, t := range n.Tokens {
+		str = append(str, t.Literal)
+	}
+	return fmt.Sprintf("comment(%s)", strings.Join(str, ", "))
+}
+
+func (_ *note) Type() NodeType {
+	return TypeComment
+}
+
+func (n *note) clone() Node {
+	c := createNote()
+	c.Tokens = append(c.Tokens, n.Tokens...)
+	return c
+}
+
+func (n *note) append(tok Token) {
+	n.Tokens = append(n.Tokens, tok)
+}
+
+func (n *note) Lines() []string {
+	var list []string
+	for _, t := range n.Tokens {
+		list = append(list, t.Literal)
+	}
+	return list
+}
+
+func (n *note) Pos() Position {
+	if len(n.Tokens) == 0 {
+		return Position{}
+	}
+	return n.Tokens[0].Position
+}
+
+func (n *note) End() Position {
+	if len(n.Tokens) == 0 {
+		return Position{}
+	}
+	return commentEnd(n.Tokens[len(n.Tokens)-1])
+}
+
+func commentEnd(tok Token) Position {
+	pos := tok.Position
+	pos.Line += strings.Count(tok.Literal, "\n")
+	return pos
+}
+
+func getComment(n Node) *note {
+	var c Node
+	switch n := n.(type) {
+	case *option:
+		c = n.Comment
+	case *object:
+		c = n.Comment
+	case *array:
+		c = n.Comment
+	case *literal:
+		c = n.Comment
+	case *variable:
+		c = n.Comment
+	case *call:
+		c = n.Comment
+	case *macro:
+		c = n.Comment
+	case *function:
+		c = n.Comment
+	case *slice:
+		return getComment(n.Node)
+	default:
+	}
+	x, _ := c.(*note)
+	return x
 }
 
-func (n note) String() string {
-	return fmt.Sprintf("comment(%s)", n.Token.Literal)
+func setComment(n Node, c Node) {
+	switch n := n.(type) {
+	case *option:
+		n.Comment = c
+	case *object:
+		n.Comment = c
+	case *array:
+		n.Comment = c
+	case *literal:
+		n.Comment = c
+	case *variable:
+		n.Comment = c
+	case *call:
+		n.Comment = c
+	case *macro:
+		n.Comment = c
+	case *function:
+		n.Comment = c
+	case *slice:
+		setComment(n.Node, c)
+	default:
+	}
 }
 
 type option struct {
-	Ident string
-	Value Node
+	Ident   string
+	Value   Node
+	Comment Node
+
+	pos Position
+	eq  Position
+	end Position
 }
 
 func createOption(ident string, value Node) *option {
@@ -60,7 +264,9 @@ func (o *option) String() string {
 }
 
 func (o *option) clone() Node {
-	return createOption(o.Ident, o.Value.clone())
+	opt := createOption(o.Ident, o.Value.clone())
+	opt.Comment = o.Comment
+	return opt
 }
 
 func (o *option) GetString() (string, error) {
@@ -131,11 +337,29 @@ func (_ *option) Type() NodeType {
 	return TypeOption
 }
 
+func (o *option) Pos() Position {
+	return o.pos
+}
+
+func (o *option) End() Position {
+	return o.end
+}
+
+func (o *option) Key() string {
+	return o.Ident
+}
+
+func (o *option) Expr() Node {
+	return o.Value
+}
+
 type object struct {
 	parent *object
 
 	Name     string
+	Labels   []string
 	Partials map[string]Node
+	Funcs    map[string]*function
 	Comment  Node
 
 	Index map[string]int
@@ -143,6 +367,8 @@ type object struct {
 	Nodes []Node
 
 	env *Env
+	pos Position
+	end Position
 }
 
 func createObject(ident string) *object {
@@ -154,6 +380,7 @@ func enclosedObject(ident string, parent *object) *object {
 		parent:   parent,
 		Name:     ident,
 		Partials: make(map[string]Node),
+		Funcs:    make(map[string]*function),
 		Index:    make(map[string]int),
 		Revex:    make(map[int]string),
 		env:      EmptyEnv(),
@@ -168,6 +395,37 @@ func (_ *object) Type() NodeType {
 	return TypeObject
 }
 
+func (o *object) Pos() Position {
+	return o.pos
+}
+
+func (o *object) End() Position {
+	return o.end
+}
+
+func (o *object) Key() string {
+	return o.Name
+}
+
+func (o *object) Tags() []string {
+	return append([]string{}, o.Labels...)
+}
+
+func (o *object) Children() []Node {
+	return append([]Node{}, o.Nodes...)
+}
+
+func (o *object) Functions() []FunctionNode {
+	var list []FunctionNode
+	for _, fn := range o.Funcs {
+		list = append(list, fn)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Func() < list[j].Func()
+	})
+	return list
+}
+
 func (o *object) Resolve(ident string) (interface{}, error) {
 	return o.env.resolve(ident)
 }
@@ -293,6 +551,23 @@ func (o *object) pluck(ori *object, keys []string, depth int64) (Node, error) {
 	return obj, nil
 }
 
+func (o *object) defineFunc(fn *function) error {
+	if _, ok := o.Funcs[fn.Ident]; ok {
+		return fmt.Errorf("%s: function already defined", fn.Ident)
+	}
+	o.Funcs[fn.Ident] = fn
+	return nil
+}
+
+func (o *object) getFunction(ident string) (*function, bool) {
+	for ; o != nil; o = o.parent {
+		if fn, ok := o.Funcs[ident]; ok {
+			return fn, ok
+		}
+	}
+	return nil, false
+}
+
 func (o *object) getObject(ident string, last bool) (*object, error) {
 	nest, ok := o.take(ident)
 	if !ok {
@@ -332,6 +607,13 @@ func (o *object) getLastObject(ident string, parent Node) (*object, error) {
 	case *array:
 		curr.Append(nest)
 		o.put(ident, curr)
+	case *option:
+		if arr, ok := curr.Value.(*array); !ok || len(arr.Nodes) > 0 {
+			return nil, notAnObject(ident)
+		}
+		arr := createArray()
+		arr.Append(nest)
+		o.put(ident, arr)
 	default:
 		return nil, notAnObject("node")
 	}
@@ -375,6 +657,13 @@ func (o *object) registerObject(obj *object) error {
 			return err
 		}
 		curr = prev
+	case *option:
+		if arr, ok := prev.Value.(*array); !ok || len(arr.Nodes) > 0 {
+			return fmt.Errorf("%s: %w", obj.Name, errRegister)
+		}
+		arr := createArray()
+		arr.Append(obj)
+		curr = arr
 	default:
 		return fmt.Errorf("%s: %w", obj.Name, errRegister)
 	}
@@ -420,6 +709,11 @@ func (o *object) merge(node Node) error {
 			return err
 		}
 	}
+	for _, fn := range obj.Funcs {
+		if err := o.defineFunc(fn); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -460,6 +754,10 @@ func (o *object) insert(node Node) error {
 	return err
 }
 
+func (o *object) append(node Node) {
+	o.Nodes = append(o.Nodes, node)
+}
+
 func (o *object) at(i int) Node {
 	return o.Nodes[i]
 }
@@ -487,6 +785,9 @@ func (o *object) put(ident string, node Node) {
 type array struct {
 	Nodes   []Node
 	Comment Node
+
+	pos Position
+	end Position
 }
 
 func createArray() *array {
@@ -516,6 +817,21 @@ func (_ *array) Type() NodeType {
 	return TypeArray
 }
 
+func (a *array) Pos() Position {
+	if a.pos.Line == 0 && len(a.Nodes) > 0 {
+		return a.Nodes[0].Pos()
+	}
+	return a.pos
+}
+
+func (a *array) End() Position {
+	return a.end
+}
+
+func (a *array) Elements() []Node {
+	return append([]Node{}, a.Nodes...)
+}
+
 func (a *array) clone() Node {
 	arr := createArray()
 	for i := range a.Nodes {
@@ -566,6 +882,10 @@ func (s *slice) Type() NodeType {
 	return TypeSlice
 }
 
+func (s *slice) Target() Node {
+	return s.Node
+}
+
 func (s *slice) clone() Node {
 	return s
 }
@@ -579,7 +899,8 @@ type Argument interface {
 }
 
 type variable struct {
-	Ident Token
+	Ident   Token
+	Comment Node
 }
 
 func createVariable(tok Token) *variable {
@@ -600,6 +921,14 @@ func (_ *variable) Type() NodeType {
 	return TypeVariable
 }
 
+func (v *variable) Pos() Position {
+	return v.Ident.Position
+}
+
+func (v *variable) End() Position {
+	return v.Ident.Position
+}
+
 func (v *variable) String() string {
 	return fmt.Sprintf("variable(%s)", v.Ident.Literal)
 }
@@ -672,6 +1001,24 @@ func (_ *template) Type() NodeType {
 	return TypeTemplate
 }
 
+func (t *template) Pos() Position {
+	if len(t.Nodes) == 0 {
+		return Position{}
+	}
+	return t.Nodes[0].Pos()
+}
+
+func (t *template) End() Position {
+	if len(t.Nodes) == 0 {
+		return Position{}
+	}
+	return t.Nodes[len(t.Nodes)-1].End()
+}
+
+func (t *template) Parts() []Node {
+	return append([]Node{}, t.Nodes...)
+}
+
 func (t *template) clone() Node {
 	var c template
 	for _, n := range t.Nodes {
@@ -711,6 +1058,26 @@ func (_ *literal) Type() NodeType {
 	return TypeLiteral
 }
 
+func (i *literal) Pos() Position {
+	return i.Token.Position
+}
+
+func (i *literal) End() Position {
+	end := i.Token.Position
+	if i.Token.Type == Heredoc {
+		end.Line += strings.Count(i.Token.Literal, "\n") + 1
+	}
+	return end
+}
+
+func (i *literal) Raw() string {
+	return i.Token.Literal
+}
+
+func (i *literal) Unit() string {
+	return i.Mul.Literal
+}
+
 func (i *literal) GetString() (string, error) {
 	return i.Token.Literal, nil
 }
@@ -755,9 +1122,14 @@ func (i *literal) clone() Node {
 }
 
 type call struct {
-	Ident  string
-	Args   []Node
-	Kwargs map[string]Node
+	Ident   string
+	Args    []Node
+	Kwargs  map[string]Node
+	Comment Node
+
+	keys  []string
+	scope *object
+	pos   Position
 }
 
 func createCall(ident string) *call {
@@ -771,6 +1143,26 @@ func (_ *call) Type() NodeType {
 	return TypeCall
 }
 
+func (c *call) Pos() Position {
+	return c.pos
+}
+
+func (c *call) End() Position {
+	return c.pos
+}
+
+func (c *call) Func() string {
+	return c.Ident
+}
+
+func (c *call) Arguments() []Node {
+	return append([]Node{}, c.Args...)
+}
+
+func (c *call) Keyword(ident string) Node {
+	return c.Kwargs[ident]
+}
+
 func (c *call) String() string {
 	return fmt.Sprintf("call(%s)", c.Ident)
 }
@@ -783,7 +1175,282 @@ func (c *call) clone() Node {
 	for k, v := range c.Kwargs {
 		a.Kwargs[k] = v.clone()
 	}
-	return nil
+	a.keys = append(a.keys, c.keys...)
+	a.scope = c.scope
+	return a
+}
+
+func (c *call) Keys() []string {
+	if len(c.keys) == len(c.Kwargs) {
+		return c.keys
+	}
+	var keys []string
+	for k := range c.Kwargs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type Parameter struct {
+	Name    string
+	Default Node
+}
+
+type function struct {
+	Ident   string
+	Params  []Parameter
+	Body    Node
+	Comment Node
+
+	pos Position
+	end Position
+}
+
+func createFunction(ident string) *function {
+	return &function{
+		Ident: ident,
+	}
+}
+
+func (_ *function) Type() NodeType {
+	return TypeFunc
+}
+
+func (f *function) Pos() Position {
+	return f.pos
+}
+
+func (f *function) End() Position {
+	return f.end
+}
+
+func (f *function) Func() string {
+	return f.Ident
+}
+
+func (f *function) Parameters() []Parameter {
+	return append([]Parameter{}, f.Params...)
+}
+
+func (f *function) Expr() Node {
+	return f.Body
+}
+
+func (f *function) String() string {
+	return fmt.Sprintf("function(%s)", f.Ident)
+}
+
+func (f *function) clone() Node {
+	fn := createFunction(f.Ident)
+	for _, p := range f.Params {
+		if p.Default != nil {
+			p.Default = p.Default.clone()
+		}
+		fn.Params = append(fn.Params, p)
+	}
+	fn.Body = f.Body.clone()
+	fn.Comment = f.Comment
+	return fn
+}
+
+func (f *function) has(ident string) bool {
+	for _, p := range f.Params {
+		if p.Name == ident {
+			return true
+		}
+	}
+	return false
+}
+
+type unary struct {
+	Op    Token
+	Right Node
+}
+
+func createUnary(op Token, right Node) *unary {
+	return &unary{
+		Op:    op,
+		Right: right,
+	}
+}
+
+func (_ *unary) Type() NodeType {
+	return TypeExpr
+}
+
+func (u *unary) Pos() Position {
+	return u.Op.Position
+}
+
+func (u *unary) End() Position {
+	return u.Right.End()
+}
+
+func (u *unary) Operator() string {
+	return u.Op.Literal
+}
+
+func (u *unary) Operand() Node {
+	return u.Right
+}
+
+func (u *unary) String() string {
+	return fmt.Sprintf("unary(%s, %s)", u.Op.Literal, u.Right)
+}
+
+func (u *unary) clone() Node {
+	return createUnary(u.Op, u.Right.clone())
+}
+
+type binary struct {
+	Op    Token
+	Left  Node
+	Right Node
+	group bool
+}
+
+func createBinary(op Token, left, right Node) *binary {
+	return &binary{
+		Op:    op,
+		Left:  left,
+		Right: right,
+	}
+}
+
+func (_ *binary) Type() NodeType {
+	return TypeExpr
+}
+
+func (b *binary) Pos() Position {
+	return b.Left.Pos()
+}
+
+func (b *binary) End() Position {
+	return b.Right.End()
+}
+
+func (b *binary) Operator() string {
+	return b.Op.Literal
+}
+
+func (b *binary) Operands() (Node, Node) {
+	return b.Left, b.Right
+}
+
+func (b *binary) String() string {
+	return fmt.Sprintf("binary(%s, %s, %s)", b.Op.Literal, b.Left, b.Right)
+}
+
+func (b *binary) clone() Node {
+	c := createBinary(b.Op, b.Left.clone(), b.Right.clone())
+	c.group = b.group
+	return c
+}
+
+type ternary struct {
+	Cond  Node
+	Csq   Node
+	Alt   Node
+	group bool
+}
+
+func createTernary(cond, csq, alt Node) *ternary {
+	return &ternary{
+		Cond: cond,
+		Csq:  csq,
+		Alt:  alt,
+	}
+}
+
+func (_ *ternary) Type() NodeType {
+	return TypeExpr
+}
+
+func (t *ternary) Pos() Position {
+	return t.Cond.Pos()
+}
+
+func (t *ternary) End() Position {
+	return t.Alt.End()
+}
+
+func (t *ternary) Branches() (Node, Node, Node) {
+	return t.Cond, t.Csq, t.Alt
+}
+
+func (t *ternary) String() string {
+	return fmt.Sprintf("ternary(%s, %s, %s)", t.Cond, t.Csq, t.Alt)
+}
+
+func (t *ternary) clone() Node {
+	c := createTernary(t.Cond.clone(), t.Csq.clone(), t.Alt.clone())
+	c.group = t.group
+	return c
+}
+
+func setGroup(n Node) {
+	switch n := n.(type) {
+	case *binary:
+		n.group = true
+	case *ternary:
+		n.group = true
+	}
+}
+
+func isGroup(n Node) bool {
+	switch n := n.(type) {
+	case *binary:
+		return n.group
+	case *ternary:
+		return n.group
+	default:
+		return false
+	}
+}
+
+type macro struct {
+	*call
+	Body Node
+
+	pos Position
+	end Position
+}
+
+func createMacro(c *call, body Node) *macro {
+	return &macro{
+		call: c,
+		Body: body,
+	}
+}
+
+func (_ *macro) Type() NodeType {
+	return TypeMacro
+}
+
+func (m *macro) Pos() Position {
+	return m.pos
+}
+
+func (m *macro) End() Position {
+	return m.end
+}
+
+func (m *macro) Block() Node {
+	return m.Body
+}
+
+func (m *macro) String() string {
+	return fmt.Sprintf("macro(%s)", m.Ident)
+}
+
+func (m *macro) clone() Node {
+	c := createMacro(m.call.clone().(*call), nil)
+	if m.Body != nil {
+		c.Body = m.Body.clone()
+	}
+	c.pos, c.end = m.pos, m.end
+	return c
 }
 
 func notAnObject(what string) error {
@@ -0,0 +1,275 @@
+package fig
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var builtins = FuncMap{
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"trim":     strings.TrimSpace,
+	"split":    strings.Split,
+	"join":     joinValues,
+	"replace":  replaceString,
+	"repeat":   repeatString,
+	"format":   sprintf,
+	"min":      minValue,
+	"max":      maxValue,
+	"abs":      absValue,
+	"round":    roundValue,
+	"len":      lenValue,
+	"contains": containsValue,
+	"unique":   uniqueValues,
+	"sort":     sortValues,
+	"keys":     keysOf,
+	"base64":   encodeBase64,
+	"hex":      encodeHex,
+	"json":     encodeJSON,
+	"md5":      hashMD5,
+	"sha256":   hashSHA256,
+	"uuid3":    uuid3,
+	"uuid5":    uuid5,
+	"now":      currentTime,
+	"timefmt":  formatTime,
+	"duration": parseDuration,
+}
+
+const maxStringSize = 1 << 20
+
+func repeatString(str string, count int) (string, error) {
+	if count < 0 {
+		return "", fmt.Errorf("negative count (%d)", count)
+	}
+	if len(str) == 0 {
+		return "", nil
+	}
+	if count > 1 && count > maxStringSize/len(str) {
+		return "", fmt.Errorf("result too large (more than %d bytes)", maxStringSize)
+	}
+	return strings.Repeat(str, count), nil
+}
+
+func replaceString(str, old, new string) (string, error) {
+	if n := len(new) - len(old); n > 0 {
+		if c := strings.Count(str, old); c > 0 && c > (maxStringSize-len(str))/n {
+			return "", fmt.Errorf("result too large (more than %d bytes)", maxStringSize)
+		}
+	}
+	return strings.ReplaceAll(str, old, new), nil
+}
+
+type joinOptions struct {
+	Sep string
+}
+
+func joinValues(list []string, opts joinOptions) string {
+	return strings.Join(list, opts.Sep)
+}
+
+func sprintf(pattern string, values ...interface{}) string {
+	return fmt.Sprintf(pattern, values...)
+}
+
+func minValue(values ...interface{}) (interface{}, error) {
+	return pickValue(makeToken("<", Lt), values)
+}
+
+func maxValue(values ...interface{}) (interface{}, error) {
+	return pickValue(makeToken(">", Gt), values)
+}
+
+func pickValue(op Token, values []interface{}) (interface{}, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("at least one value expected")
+	}
+	res := values[0]
+	for _, v := range values[1:] {
+		ok, err := compareValues(op, v, res)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = v
+		}
+	}
+	return res, nil
+}
+
+func absValue(value interface{}) (interface{}, error) {
+	if i, ok := toInt(value); ok {
+		if i < 0 {
+			i = -i
+		}
+		return i, nil
+	}
+	if f, ok := toFloat(value); ok {
+		return math.Abs(f), nil
+	}
+	return nil, fmt.Errorf("number expected! got %T", value)
+}
+
+func roundValue(value float64) int64 {
+	return int64(math.Round(value))
+}
+
+func lenValue(value interface{}) (int64, error) {
+	if str, ok := value.(string); ok {
+		return int64(utf8.RuneCountInString(str)), nil
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return int64(v.Len()), nil
+	default:
+		return 0, fmt.Errorf("%T has no length", value)
+	}
+}
+
+func containsValue(value, item interface{}) (bool, error) {
+	if str, ok := value.(string); ok {
+		return strings.Contains(str, toString(item)), nil
+	}
+	v := reflect.ValueOf(value)
+	if !isArray(v) {
+		return false, fmt.Errorf("string or array expected! got %T", value)
+	}
+	for i := 0; i < v.Len(); i++ {
+		if isEqual(v.Index(i).Interface(), item) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func uniqueValues(list []interface{}) []interface{} {
+	var values []interface{}
+	for _, v := range list {
+		var found bool
+		for _, other := range values {
+			if found = isEqual(v, other); found {
+				break
+			}
+		}
+		if !found {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func sortValues(list []interface{}) ([]interface{}, error) {
+	var (
+		values = append([]interface{}{}, list...)
+		less   = makeToken("<", Lt)
+		err    error
+	)
+	sort.SliceStable(values, func(i, j int) bool {
+		ok, err1 := compareValues(less, values[i], values[j])
+		if err1 != nil {
+			err = err1
+		}
+		return ok
+	})
+	return values, err
+}
+
+func keysOf(value interface{}) ([]string, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map {
+		return nil, fmt.Errorf("object expected! got %T", value)
+	}
+	var keys []string
+	for _, k := range v.MapKeys() {
+		keys = append(keys, toString(k.Interface()))
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func encodeBase64(str string) string {
+	return base64.StdEncoding.EncodeToString([]byte(str))
+}
+
+func encodeHex(str string) string {
+	return hex.EncodeToString([]byte(str))
+}
+
+func encodeJSON(value interface{}) (string, error) {
+	buf, err := json.Marshal(value)
+	return string(buf), err
+}
+
+func hashMD5(str string) string {
+	sum := md5.Sum([]byte(str))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashSHA256(str string) string {
+	sum := sha256.Sum256([]byte(str))
+	return hex.EncodeToString(sum[:])
+}
+
+var namespaces = map[string]string{
+	"dns":  "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	"url":  "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+	"oid":  "6ba7b812-9dad-11d1-80b4-00c04fd430c8",
+	"x500": "6ba7b814-9dad-11d1-80b4-00c04fd430c8",
+}
+
+func uuid3(namespace, name string) (string, error) {
+	return makeUUID(md5.New(), 3, namespace, name)
+}
+
+func uuid5(namespace, name string) (string, error) {
+	return makeUUID(sha1.New(), 5, namespace, name)
+}
+
+func makeUUID(h hash.Hash, version byte, namespace, name string) (string, error) {
+	if ns, ok := namespaces[strings.ToLower(namespace)]; ok {
+		namespace = ns
+	}
+	ns, err := hex.DecodeString(strings.ReplaceAll(namespace, "-", ""))
+	if err != nil || len(ns) != 16 {
+		return "", fmt.Errorf("%s: invalid uuid namespace", namespace)
+	}
+	h.Write(ns)
+	h.Write([]byte(name))
+
+	sum := h.Sum(nil)[:16]
+	sum[6] = (sum[6] & 0x0f) | version<<4
+	sum[8] = (sum[8] & 0x3f) | 0x80
+
+	str := hex.EncodeToString(sum)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", str[:8], str[8:12], str[12:16], str[16:20], str[20:]), nil
+}
+
+func currentTime() string {
+	return time.Now().UTC().Format(timeformat[0])
+}
+
+func formatTime(str, layout string) (string, error) {
+	for _, f := range append([]string{time.RFC3339}, timeformat...) {
+		when, err := time.Parse(f, str)
+		if err == nil {
+			return when.Format(layout), nil
+		}
+	}
+	return "", fmt.Errorf("%s: invalid time", str)
+}
+
+func parseDuration(str string) (float64, error) {
+	d, err := time.ParseDuration(str)
+	return d.Seconds(), err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/midbel/fig"
+)
+
+func runConvert(args []string) error {
+	var (
+		set = flag.NewFlagSet("convert", flag.ExitOnError)
+		to  = set.String("t", "", "output format (json, fig)")
+		env = make(defines)
+	)
+	set.Var(env, "D", "define a variable available to the document (name=value)")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	var (
+		file = set.Arg(0)
+		in   io.Reader
+	)
+	if file == "" || file == "-" {
+		in = os.Stdin
+	} else {
+		r, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		in = r
+	}
+	if *to == "" {
+		*to = "json"
+		if strings.EqualFold(filepath.Ext(file), ".json") {
+			*to = "fig"
+		}
+	}
+	var err error
+	switch strings.ToLower(*to) {
+	case "json":
+		dec := fig.NewDecoder(in)
+		for k, v := range env {
+			dec.Define(k, v)
+		}
+		err = dec.DecodeJSON(os.Stdout)
+	case "fig":
+		err = fig.FromJSON(in, os.Stdout)
+	default:
+		return fmt.Errorf("%s: unsupported output format", *to)
+	}
+	if err != nil && file != "" {
+		err = fmt.Errorf("%s: %w", file, err)
+	}
+	return err
+}
+
+type defines map[string]string
+
+func (d defines) String() string {
+	var list []string
+	for k, v := range d {
+		list = append(list, k+"="+v)
+	}
+	return strings.Join(list, ",")
+}
+
+func (d defines) Set(str string) error {
+	x := strings.Index(str, "=")
+	if x <= 0 {
+		return fmt.Errorf("%s: invalid definition (name=value expected)", str)
+	}
+	d[str[:x]] = str[x+1:]
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+type edit struct {
+	op   byte
+	a, b int
+}
+
+func unifiedDiff(file1, file2 string, b1, b2 []byte) []byte {
+	var (
+		a     = splitLines(b1)
+		b     = splitLines(b2)
+		edits = diffLines(a, b)
+		buf   bytes.Buffer
+	)
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", file1, file2)
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+		beg := i - diffContext
+		if beg < 0 {
+			beg = 0
+		}
+		end := i
+		for j := i; j < len(edits) && j-end <= 2*diffContext; j++ {
+			if edits[j].op != ' ' {
+				end = j
+			}
+		}
+		i = end + 1
+		if end += diffContext + 1; end > len(edits) {
+			end = len(edits)
+		}
+		writeHunk(&buf, a, b, edits[beg:end])
+	}
+	return buf.Bytes()
+}
+
+func writeHunk(buf *bytes.Buffer, a, b []string, edits []edit) {
+	var na, nb int
+	for _, e := range edits {
+		if e.op != '+' {
+			na++
+		}
+		if e.op != '-' {
+			nb++
+		}
+	}
+	span := func(at, n int) string {
+		switch n {
+		case 0:
+			return fmt.Sprintf("%d,0", at)
+		case 1:
+			return fmt.Sprint(at + 1)
+		default:
+			return fmt.Sprintf("%d,%d", at+1, n)
+		}
+	}
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", span(edits[0].a, na), span(edits[0].b, nb))
+	for _, e := range edits {
+		var line string
+		if e.op == '+' {
+			line = b[e.b]
+		} else {
+			line = a[e.a]
+		}
+		buf.WriteByte(e.op)
+		buf.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func splitLines(data []byte) []string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	return lines
+}
+
+func diffLines(a, b []string) []edit {
+	var (
+		n, m  = len(a), len(b)
+		max   = n + m
+		v     = make([]int, 2*max+2)
+		trace [][]int
+	)
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, max, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, max, x, y int) []edit {
+	var edits []edit
+	for d := len(trace) - 1; d >= 0; d-- {
+		var (
+			v     = trace[d]
+			k     = x - y
+			prevK int
+		)
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY && x > 0 && y > 0 {
+			x--
+			y--
+			edits = append(edits, edit{op: ' ', a: x, b: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{op: '+', a: x, b: y})
+		} else {
+			x--
+			edits = append(edits, edit{op: '-', a: x, b: y})
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		Old  string
+		New  string
+		Want string
+	}{
+		{
+			Old:  "a=1\nb = 2\n",
+			New:  "a = 1\nb = 2\n",
+			Want: "--- x.orig\n+++ x\n@@ -1,2 +1,2 @@\n-a=1\n+a = 1\n b = 2\n",
+		},
+		{
+			Old:  "",
+			New:  "a = 1\n",
+			Want: "--- x.orig\n+++ x\n@@ -0,0 +1 @@\n+a = 1\n",
+		},
+		{
+			Old:  "a = 1",
+			New:  "a = 1\n",
+			Want: "--- x.orig\n+++ x\n@@ -1 +1 @@\n-a = 1\n\\ No newline at end of file\n+a = 1\n",
+		},
+		{
+			Old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			New:  "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			Want: "--- x.orig\n+++ x\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -7,4 +8,3 @@\n 7\n 8\n 9\n-10\n",
+		},
+	}
+	for _, tt := range tests {
+		got := unifiedDiff("x.orig", "x", []byte(tt.Old), []byte(tt.New))
+		if string(got) != tt.Want {
+			t.Errorf("diff mismatch!\nwant:\n%s\ngot:\n%s", tt.Want, got)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/midbel/fig"
+)
+
+var errFormat = errors.New("files not formatted")
+
+func runFormat(args []string) error {
+	var (
+		set  = flag.NewFlagSet("fmt", flag.ExitOnError)
+		list = set.Bool("l", false, "list files whose formatting differs from canonical form")
+		diff = set.Bool("d", false, "display diffs instead of printing formatted files")
+		bad  int
+	)
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	for _, file := range set.Args() {
+		ok, err := formatFile(file, *list, *diff)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			bad++
+		}
+	}
+	if bad > 0 && (*list || *diff) {
+		return fmt.Errorf("%d %w", bad, errFormat)
+	}
+	return nil
+}
+
+func formatFile(file string, list, diff bool) (bool, error) {
+	in, err := os.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+	var out bytes.Buffer
+	if err := fig.Format(bytes.NewReader(in), &out); err != nil {
+		return false, fmt.Errorf("%s: %w", file, err)
+	}
+	ok := bytes.Equal(in, out.Bytes())
+	if !list && !diff {
+		_, err = os.Stdout.Write(out.Bytes())
+		return ok, err
+	}
+	if ok {
+		return ok, nil
+	}
+	if list {
+		fmt.Println(file)
+	}
+	if diff {
+		os.Stdout.Write(unifiedDiff(file+".orig", file, in, out.Bytes()))
+	}
+	return ok, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	Usage string
+	Short string
+	Run   func([]string) error
+}
+
+var commands = map[string]command{
+	"fmt": {
+		Usage: "fmt [-l] [-d] <file...>",
+		Short: "print fig files in canonical form",
+		Run:   runFormat,
+	},
+	"convert": {
+		Usage: "convert [-t json|fig] [-D name=value] <file>",
+		Short: "convert fig files to json and json files to fig",
+		Run:   runConvert,
+	},
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+	args := flag.Args()
+	if err := cmd.Run(args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	var names []string
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage: fig <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "available commands:")
+	for _, n := range names {
+		cmd := commands[n]
+		fmt.Fprintf(os.Stderr, "  %-32s %s\n", cmd.Usage, cmd.Short)
+	}
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/midbel/fig"
+)
+
+const maxDepth = 64
+
+var (
+	reMacro  = regexp.MustCompile(`(^|\s)\.(\w*)$`)
+	reKwargs = regexp.MustCompile(`\.(\w+)\(([^)]*)$`)
+)
+
+type file struct {
+	URI         string
+	Text        string
+	Root        fig.Node
+	Diagnostics []Diagnostic
+
+	lines []string
+}
+
+func parseFile(uri, text string) *file {
+	f := file{
+		URI:   uri,
+		Text:  text,
+		lines: strings.Split(text, "\n"),
+	}
+	p, err := fig.NewParser(strings.NewReader(text))
+	if err == nil {
+		p.Raw()
+		p.Tolerant()
+		f.Root, err = p.Parse()
+	}
+	f.Diagnostics = diagnose(err)
+	return &f
+}
+
+func diagnose(err error) []Diagnostic {
+	var (
+		list fig.ErrorList
+		pe   *fig.ParseError
+		diag = []Diagnostic{}
+	)
+	switch {
+	case err == nil:
+		return diag
+	case errors.As(err, &list):
+	case errors.As(err, &pe):
+		list = append(list, pe)
+	default:
+		d := Diagnostic{
+			Severity: severityError,
+			Source:   "figls",
+			Message:  err.Error(),
+		}
+		return append(diag, d)
+	}
+	for _, e := range list {
+		var (
+			pos  = toPosition(fig.Position{Line: e.Line, Col: e.Column})
+			size = len(e.Token.Literal)
+			msg  = e.Err.Error()
+		)
+		if size == 0 {
+			size = 1
+		}
+		if e.Path != "" {
+			msg = fmt.Sprintf("%s: %s", e.Path, msg)
+		}
+		d := Diagnostic{
+			Range:    Range{Start: pos, End: Position{Line: pos.Line, Character: pos.Character + size}},
+			Severity: severityError,
+			Source:   "figls",
+			Message:  msg,
+		}
+		diag = append(diag, d)
+	}
+	return diag
+}
+
+func (f *file) symbols() []DocumentSymbol {
+	root, ok := f.Root.(fig.ObjectNode)
+	if !ok {
+		return []DocumentSymbol{}
+	}
+	return symbolsOf(root.Children())
+}
+
+func symbolsOf(nodes []fig.Node) []DocumentSymbol {
+	list := []DocumentSymbol{}
+	for _, n := range nodes {
+		var sym DocumentSymbol
+		switch n := n.(type) {
+		case fig.ObjectNode:
+			name := strings.Join(append([]string{n.Key()}, n.Tags()...), " ")
+			sym = makeSymbol(name, symbolObject, n, len(n.Key()))
+			sym.Children = symbolsOf(n.Children())
+		case fig.OptionNode:
+			sym = makeSymbol(n.Key(), symbolProperty, n, len(n.Key()))
+		case fig.MacroNode:
+			name := macroArg(n, 0, "name")
+			if n.Func() != "define" || name == "" {
+				continue
+			}
+			sym = makeSymbol(name, symbolNamespace, n, len(n.Func())+1)
+			sym.Detail = ".define"
+			if b, ok := n.Block().(fig.ObjectNode); ok {
+				sym.Children = symbolsOf(b.Children())
+			}
+		default:
+			continue
+		}
+		list = append(list, sym)
+	}
+	return list
+}
+
+func makeSymbol(name string, kind int, n fig.Node, size int) DocumentSymbol {
+	return DocumentSymbol{
+		Name:           name,
+		Kind:           kind,
+		Range:          nodeRange(n),
+		SelectionRange: spanRange(n.Pos(), size),
+	}
+}
+
+func (f *file) hover(pos Position) *Hover {
+	var (
+		stack []fig.Node
+		found fig.VariableNode
+		path  []fig.Node
+	)
+	fig.Inspect(f.Root, func(n fig.Node) bool {
+		if found != nil {
+			return false
+		}
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		if v, ok := n.(fig.VariableNode); ok && f.covers(v, pos) {
+			found = v
+			path = append(path, stack...)
+		}
+		return true
+	})
+	if found == nil {
+		return nil
+	}
+	var str string
+	if found.IsLocal() {
+		value, ok := f.resolve(found, path, 0)
+		if !ok {
+			value = "undefined"
+		}
+		str = fmt.Sprintf("$%s = %s", found.Name(), value)
+	} else {
+		value, ok := os.LookupEnv(found.Name())
+		if !ok {
+			str = fmt.Sprintf("@%s is not set", found.Name())
+		} else {
+			str = fmt.Sprintf("@%s = %q", found.Name(), value)
+		}
+	}
+	rg := spanRange(found.Pos(), f.width(found))
+	h := Hover{
+		Contents: MarkupContent{
+			Kind:  markdown,
+			Value: fmt.Sprintf("```fig\n%s\n```", str),
+		},
+		Range: &rg,
+	}
+	return &h
+}
+
+func (f *file) resolve(v fig.VariableNode, path []fig.Node, depth int) (string, bool) {
+	if !v.IsLocal() {
+		str, ok := os.LookupEnv(v.Name())
+		return fmt.Sprintf("%q", str), ok
+	}
+	if depth >= maxDepth {
+		return "", false
+	}
+	var skip fig.Node
+	for i := len(path) - 1; i >= 0; i-- {
+		switch n := path[i].(type) {
+		case fig.OptionNode:
+			if skip == nil {
+				skip = n
+			}
+		case fig.ObjectNode:
+			children := n.Children()
+			for j := len(children) - 1; j >= 0; j-- {
+				opt, ok := children[j].(fig.OptionNode)
+				if !ok || opt == skip || opt.Key() != v.Name() {
+					continue
+				}
+				return f.render(opt, path[:i+1], depth+1), true
+			}
+		}
+	}
+	return "", false
+}
+
+func (f *file) render(opt fig.OptionNode, path []fig.Node, depth int) string {
+	path = append(append([]fig.Node{}, path...), opt)
+	switch n := opt.Expr().(type) {
+	case nil:
+		return "null"
+	case fig.LiteralNode:
+		v, err := n.Get()
+		if err != nil {
+			break
+		}
+		if str, ok := v.(string); ok {
+			return fmt.Sprintf("%q", str)
+		}
+		return fmt.Sprint(v)
+	case fig.VariableNode:
+		if str, ok := f.resolve(n, path, depth); ok {
+			return str
+		}
+	case fig.TemplateNode:
+		var str strings.Builder
+		for _, n := range n.Parts() {
+			switch n := n.(type) {
+			case fig.LiteralNode:
+				str.WriteString(n.Raw())
+			case fig.VariableNode:
+				v, ok := f.resolve(n, path, depth)
+				if !ok {
+					return f.source(opt)
+				}
+				str.WriteString(strings.Trim(v, "\""))
+			}
+		}
+		return fmt.Sprintf("%q", str.String())
+	}
+	return f.source(opt)
+}
+
+func (f *file) source(opt fig.OptionNode) string {
+	expr := opt.Expr()
+	if expr == nil {
+		return ""
+	}
+	var (
+		pos = expr.Pos()
+		end = opt.End()
+	)
+	if pos.Offset < 0 || pos.Offset > len(f.Text) {
+		return ""
+	}
+	str := f.Text[pos.Offset:]
+	if end.Line == pos.Line && end.Offset >= pos.Offset && end.Offset <= len(f.Text) {
+		str = f.Text[pos.Offset:end.Offset]
+	} else if x := strings.IndexByte(str, '\n'); x >= 0 {
+		str = str[:x]
+	}
+	return strings.TrimSpace(str)
+}
+
+func (f *file) covers(v fig.VariableNode, pos Position) bool {
+	at := toPosition(v.Pos())
+	if at.Line != pos.Line {
+		return false
+	}
+	return pos.Character >= at.Character && pos.Character < at.Character+f.width(v)
+}
+
+func (f *file) width(v fig.VariableNode) int {
+	var (
+		pos  = toPosition(v.Pos())
+		size = len(v.Name()) + 1
+	)
+	if pos.Line < len(f.lines) {
+		line := f.lines[pos.Line]
+		if x := pos.Character + 1; x < len(line) && line[x] == '{' {
+			size += 2
+		}
+	}
+	return size
+}
+
+func (f *file) definition(pos Position) []Location {
+	m := f.macroAt(pos)
+	if m == nil {
+		return nil
+	}
+	switch m.Func() {
+	case "apply", "extend":
+		name := macroArg(m, 0, "name")
+		if name == "" {
+			return nil
+		}
+		var list []Location
+		fig.Inspect(f.Root, func(n fig.Node) bool {
+			d, ok := n.(fig.MacroNode)
+			if ok && d.Func() == "define" && macroArg(d, 0, "name") == name {
+				loc := Location{
+					URI:   f.URI,
+					Range: spanRange(d.Pos(), len(d.Func())+1),
+				}
+				list = append(list, loc)
+			}
+			return true
+		})
+		return list
+	case "include":
+		file := f.locate(macroArg(m, 0, "file"))
+		if file == "" {
+			return nil
+		}
+		u := url.URL{
+			Scheme: "file",
+			Path:   filepath.ToSlash(file),
+		}
+		return []Location{{URI: u.String()}}
+	default:
+		return nil
+	}
+}
+
+func (f *file) locate(file string) string {
+	if file == "" {
+		return ""
+	}
+	if u, err := url.Parse(file); err == nil && u.Scheme != "" && u.Scheme != "file" {
+		return ""
+	} else if err == nil && u.Scheme == "file" {
+		file = u.Path
+	}
+	if !filepath.IsAbs(file) {
+		if u, err := url.Parse(f.URI); err == nil && u.Scheme == "file" {
+			file = filepath.Join(filepath.Dir(filepath.FromSlash(u.Path)), file)
+		}
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	if _, err := os.Stat(file); err != nil {
+		return ""
+	}
+	return file
+}
+
+func (f *file) macroAt(pos Position) fig.MacroNode {
+	var found fig.MacroNode
+	fig.Inspect(f.Root, func(n fig.Node) bool {
+		m, ok := n.(fig.MacroNode)
+		if !ok {
+			return true
+		}
+		at := toPosition(m.Pos())
+		if at.Line == pos.Line && at.Character <= pos.Character {
+			found = m
+		}
+		return true
+	})
+	return found
+}
+
+func (f *file) complete(pos Position, macros []fig.MacroInfo) CompletionList {
+	list := CompletionList{
+		Items: []CompletionItem{},
+	}
+	if pos.Line >= len(f.lines) {
+		return list
+	}
+	line := f.lines[pos.Line]
+	if pos.Character < len(line) {
+		line = line[:pos.Character]
+	}
+	if m := reKwargs.FindStringSubmatch(line); m != nil {
+		for _, i := range macros {
+			if i.Name != m[1] {
+				continue
+			}
+			for _, p := range i.Params {
+				if strings.Contains(m[2], p+"=") {
+					continue
+				}
+				c := CompletionItem{
+					Label:      p,
+					Kind:       completionProperty,
+					Detail:     fmt.Sprintf(".%s(%s=)", i.Name, p),
+					InsertText: p + "=",
+				}
+				list.Items = append(list.Items, c)
+			}
+		}
+		return list
+	}
+	m := reMacro.FindStringSubmatch(line)
+	if m == nil {
+		return list
+	}
+	for _, i := range macros {
+		if !strings.HasPrefix(i.Name, m[2]) {
+			continue
+		}
+		detail := fmt.Sprintf(".%s(%s)", i.Name, strings.Join(i.Params, ", "))
+		if i.Block {
+			detail += " { ... }"
+		}
+		c := CompletionItem{
+			Label:  i.Name,
+			Kind:   completionFunction,
+			Detail: detail,
+		}
+		list.Items = append(list.Items, c)
+	}
+	return list
+}
+
+func macroArg(m fig.MacroNode, i int, key string) string {
+	n := m.Keyword(key)
+	if args := m.Arguments(); n == nil && i < len(args) {
+		n = args[i]
+	}
+	lit, ok := n.(fig.LiteralNode)
+	if !ok {
+		return ""
+	}
+	return lit.Raw()
+}
+
+func toPosition(pos fig.Position) Position {
+	p := Position{
+		Line:      pos.Line - 1,
+		Character: pos.Col - 1,
+	}
+	if p.Line < 0 {
+		p.Line = 0
+	}
+	if p.Character < 0 {
+		p.Character = 0
+	}
+	return p
+}
+
+func spanRange(pos fig.Position, size int) Range {
+	start := toPosition(pos)
+	return Range{
+		Start: start,
+		End:   Position{Line: start.Line, Character: start.Character + size},
+	}
+}
+
+func nodeRange(n fig.Node) Range {
+	var (
+		start = toPosition(n.Pos())
+		end   = toPosition(n.End())
+	)
+	end.Character++
+	if end.Line < start.Line || (end.Line == start.Line && end.Character < start.Character) {
+		end = start
+	}
+	return Range{Start: start, End: end}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type client struct {
+	t    *testing.T
+	in   *bufio.Reader
+	out  io.WriteCloser
+	id   int
+	errc chan error
+
+	diagnostics map[string][]Diagnostic
+}
+
+func startClient(t *testing.T) *client {
+	t.Helper()
+	var (
+		sr, cw = io.Pipe()
+		cr, sw = io.Pipe()
+		c      = client{
+			t:           t,
+			in:          bufio.NewReader(cr),
+			out:         cw,
+			errc:        make(chan error, 1),
+			diagnostics: make(map[string][]Diagnostic),
+		}
+	)
+	go func() {
+		err := newServer(sr, sw).serve()
+		sw.Close()
+		c.errc <- err
+	}()
+	c.call("initialize", InitializeParams{}, nil)
+	c.notify("initialized", struct{}{})
+	return &c
+}
+
+func (c *client) stop() {
+	c.t.Helper()
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.errc; err != nil {
+		c.t.Fatalf("server stopped with error: %s", err)
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	msg := notification{
+		Version: version,
+		Method:  method,
+		Params:  params,
+	}
+	if err := writeMessage(c.out, msg); err != nil {
+		c.t.Fatalf("%s: fail to send notification: %s", method, err)
+	}
+}
+
+func (c *client) call(method string, params, result interface{}) {
+	c.t.Helper()
+	c.id++
+	msg := struct {
+		Version string      `json:"jsonrpc"`
+		ID      int         `json:"id"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params,omitempty"`
+	}{
+		Version: version,
+		ID:      c.id,
+		Method:  method,
+		Params:  params,
+	}
+	if err := writeMessage(c.out, msg); err != nil {
+		c.t.Fatalf("%s: fail to send request: %s", method, err)
+	}
+	for {
+		res := c.read()
+		if res.Error != nil {
+			c.t.Fatalf("%s: unexpected error: %s", method, res.Error)
+		}
+		if string(res.ID) != fmt.Sprint(c.id) {
+			continue
+		}
+		if result != nil {
+			if err := json.Unmarshal(res.Result, result); err != nil {
+				c.t.Fatalf("%s: fail to decode result: %s", method, err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) read() response {
+	c.t.Helper()
+	buf, err := readMessage(c.in)
+	if err != nil {
+		c.t.Fatalf("fail to read message: %s", err)
+	}
+	var msg struct {
+		response
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(buf, &msg); err != nil {
+		c.t.Fatalf("fail to decode message: %s", err)
+	}
+	if msg.Method == "textDocument/publishDiagnostics" {
+		var diag PublishDiagnosticsParams
+		json.Unmarshal(msg.Params, &diag)
+		c.diagnostics[diag.URI] = diag.Diagnostics
+	}
+	return msg.response
+}
+
+func (c *client) open(uri, text string) {
+	c.t.Helper()
+	item := DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{
+			URI:        uri,
+			LanguageID: "fig",
+			Version:    1,
+			Text:       text,
+		},
+	}
+	c.notify("textDocument/didOpen", item)
+	c.read()
+}
+
+func at(uri string, line, char int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: char},
+	}
+}
+
+const sample = `name = "demo"
+port = 80
+.define(proxy) {
+  host = $name
+}
+server {
+  addr = @FIGLS_ADDR
+  url  = ` + "`http://${addr}:${port}`" + `
+  .apply(proxy)
+}
+.include("other.fig", fatal=true)
+`
+
+func setup(t *testing.T) (*client, string) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "other.fig"), []byte("x = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	u := url.URL{
+		Scheme: "file",
+		Path:   filepath.ToSlash(filepath.Join(dir, "main.fig")),
+	}
+	c := startClient(t)
+	c.open(u.String(), sample)
+	return c, u.String()
+}
+
+func TestDiagnostics(t *testing.T) {
+	c, uri := setup(t)
+	defer c.stop()
+
+	if diag := c.diagnostics[uri]; len(diag) != 0 {
+		t.Fatalf("no diagnostics expected! got %v", diag)
+	}
+	change := DidChangeTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{
+			{Text: "server {\n  port = 80 +\n  addr = \n}\nuser = \n"},
+		},
+	}
+	c.notify("textDocument/didChange", change)
+	c.read()
+
+	diag := c.diagnostics[uri]
+	if len(diag) != 1 {
+		t.Fatalf("one diagnostic expected! got %v", diag)
+	}
+	if want := (Position{Line: 1, Character: 13}); diag[0].Range.Start != want {
+		t.Errorf("diagnostic: want position %v, got %v", want, diag[0].Range.Start)
+	}
+	if !strings.HasPrefix(diag[0].Message, "server: ") {
+		t.Errorf("diagnostic: unexpected message %q", diag[0].Message)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	c, uri := setup(t)
+	defer c.stop()
+
+	var list []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &list)
+
+	var names []string
+	for _, s := range list {
+		names = append(names, s.Name)
+		for _, c := range s.Children {
+			names = append(names, s.Name+"."+c.Name)
+		}
+	}
+	want := "name port proxy proxy.host server server.addr server.url"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("symbols: want %q, got %q", want, got)
+	}
+	if list[2].Kind != symbolNamespace || list[3].Kind != symbolObject {
+		t.Errorf("symbols: unexpected kinds %d and %d", list[2].Kind, list[3].Kind)
+	}
+}
+
+func TestHover(t *testing.T) {
+	os.Setenv("FIGLS_ADDR", "localhost")
+	defer os.Unsetenv("FIGLS_ADDR")
+
+	c, uri := setup(t)
+	defer c.stop()
+
+	tests := []struct {
+		Line int
+		Char int
+		Want string
+	}{
+		{Line: 3, Char: 10, Want: `$name = "demo"`},
+		{Line: 6, Char: 11, Want: `@FIGLS_ADDR = "localhost"`},
+		{Line: 7, Char: 29, Want: `$port = 80`},
+		{Line: 7, Char: 20, Want: `$addr = "localhost"`},
+	}
+	for _, tt := range tests {
+		var h Hover
+		c.call("textDocument/hover", at(uri, tt.Line, tt.Char), &h)
+		if !strings.Contains(h.Contents.Value, tt.Want) {
+			t.Errorf("hover(%d:%d): want %q, got %q", tt.Line, tt.Char, tt.Want, h.Contents.Value)
+		}
+	}
+	var h *Hover
+	c.call("textDocument/hover", at(uri, 0, 2), &h)
+	if h != nil {
+		t.Errorf("hover: no result expected! got %v", h)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c, uri := setup(t)
+	defer c.stop()
+
+	var list []Location
+	c.call("textDocument/definition", at(uri, 8, 5), &list)
+	if len(list) != 1 || list[0].URI != uri || list[0].Range.Start != (Position{Line: 2}) {
+		t.Errorf("apply: unexpected locations %v", list)
+	}
+
+	list = list[:0]
+	c.call("textDocument/definition", at(uri, 10, 12), &list)
+	if len(list) != 1 || !strings.HasSuffix(list[0].URI, "/other.fig") {
+		t.Errorf("include: unexpected locations %v", list)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c, uri := setup(t)
+	defer c.stop()
+
+	edit := func(text string) {
+		change := DidChangeTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			ContentChanges: []TextDocumentContentChangeEvent{
+				{Text: text},
+			},
+		}
+		c.notify("textDocument/didChange", change)
+		c.read()
+	}
+	labels := func(list CompletionList) string {
+		var str []string
+		for _, i := range list.Items {
+			str = append(str, i.Label)
+		}
+		return strings.Join(str, " ")
+	}
+
+	var list CompletionList
+	edit("server {\n  .in\n}\n")
+	c.call("textDocument/completion", at(uri, 1, 5), &list)
+	if got := labels(list); got != "include" {
+		t.Errorf("macros: want include, got %q", got)
+	}
+
+	edit(".include(\"other.fig\", fatal=true, \n")
+	c.call("textDocument/completion", at(uri, 0, 35), &list)
+	if got := labels(list); got != "file name method once sha256" {
+		t.Errorf("kwargs: want %q, got %q", "file name method once sha256", got)
+	}
+}
+
+func TestDiagnosticsTemplate(t *testing.T) {
+	c, uri := setup(t)
+	defer c.stop()
+
+	change := DidChangeTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{
+			{Text: "name = demo\nurl = `http://${name}\n"},
+		},
+	}
+	c.notify("textDocument/didChange", change)
+	c.read()
+
+	diag := c.diagnostics[uri]
+	if len(diag) != 1 {
+		t.Fatalf("one diagnostic expected! got %v", diag)
+	}
+	if !strings.Contains(diag[0].Message, "unterminated template") {
+		t.Errorf("diagnostic: unexpected message %q", diag[0].Message)
+	}
+}
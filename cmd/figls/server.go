@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/midbel/fig"
+)
+
+var errExit = errors.New("exit without shutdown")
+
+type handler func(*server, json.RawMessage) (interface{}, error)
+
+var requests = map[string]handler{
+	"initialize":                  (*server).initialize,
+	"shutdown":                    (*server).shutdown,
+	"textDocument/documentSymbol": (*server).documentSymbol,
+	"textDocument/hover":          (*server).hover,
+	"textDocument/definition":     (*server).definition,
+	"textDocument/completion":     (*server).completion,
+}
+
+var notifications = map[string]handler{
+	"textDocument/didOpen":   (*server).didOpen,
+	"textDocument/didChange": (*server).didChange,
+	"textDocument/didClose":  (*server).didClose,
+}
+
+type server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	files  map[string]*file
+	macros []fig.MacroInfo
+
+	initialized bool
+	done        bool
+}
+
+func newServer(r io.Reader, w io.Writer) *server {
+	s := server{
+		in:    bufio.NewReader(r),
+		out:   w,
+		files: make(map[string]*file),
+	}
+	if p, err := fig.NewParser(strings.NewReader("")); err == nil {
+		s.macros = p.Macros()
+	}
+	return &s
+}
+
+func (s *server) serve() error {
+	for {
+		buf, err := readMessage(s.in)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(buf, &req); err != nil {
+			if err := s.reply(nil, nil, &rpcError{Code: codeParse, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.done {
+				return errExit
+			}
+			return nil
+		}
+		if req.isNotification() {
+			if err := s.notify(req); err != nil {
+				return err
+			}
+			continue
+		}
+		res, err := s.call(req)
+		if err := s.reply(req.ID, res, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) call(req request) (interface{}, error) {
+	h, ok := requests[req.Method]
+	switch {
+	case !ok:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: req.Method + ": method not found"}
+	case s.done:
+		return nil, &rpcError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	case !s.initialized && req.Method != "initialize":
+		return nil, &rpcError{Code: codeNotInitialized, Message: "server not initialized"}
+	default:
+		return h(s, req.Params)
+	}
+}
+
+func (s *server) notify(req request) error {
+	h, ok := notifications[req.Method]
+	if !ok || !s.initialized {
+		return nil
+	}
+	_, err := h(s, req.Params)
+	var e *rpcError
+	if errors.As(err, &e) {
+		return nil
+	}
+	return err
+}
+
+func (s *server) reply(id json.RawMessage, res interface{}, err error) error {
+	msg := response{
+		Version: version,
+		ID:      id,
+	}
+	if err == nil {
+		msg.Result, err = json.Marshal(res)
+	}
+	if err != nil {
+		var e *rpcError
+		if !errors.As(err, &e) {
+			e = &rpcError{Code: codeInternal, Message: err.Error()}
+		}
+		msg.Result, msg.Error = nil, e
+	}
+	return writeMessage(s.out, msg)
+}
+
+func (s *server) publish(method string, params interface{}) error {
+	msg := notification{
+		Version: version,
+		Method:  method,
+		Params:  params,
+	}
+	return writeMessage(s.out, msg)
+}
+
+func (s *server) initialize(params json.RawMessage) (interface{}, error) {
+	var req InitializeParams
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	s.initialized = true
+	res := InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:       syncFull,
+			DocumentSymbolProvider: true,
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			CompletionProvider: &CompletionOptions{
+				TriggerCharacters: []string{".", "(", ","},
+			},
+		},
+		ServerInfo: ServerInfo{
+			Name: "figls",
+		},
+	}
+	return res, nil
+}
+
+func (s *server) shutdown(_ json.RawMessage) (interface{}, error) {
+	s.done = true
+	return nil, nil
+}
+
+func (s *server) didOpen(params json.RawMessage) (interface{}, error) {
+	var req DidOpenTextDocumentParams
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	return nil, s.update(req.TextDocument.URI, req.TextDocument.Text)
+}
+
+func (s *server) didChange(params json.RawMessage) (interface{}, error) {
+	var req DidChangeTextDocumentParams
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if len(req.ContentChanges) == 0 {
+		return nil, nil
+	}
+	last := req.ContentChanges[len(req.ContentChanges)-1]
+	return nil, s.update(req.TextDocument.URI, last.Text)
+}
+
+func (s *server) didClose(params json.RawMessage) (interface{}, error) {
+	var req DidCloseTextDocumentParams
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	delete(s.files, req.TextDocument.URI)
+	diag := PublishDiagnosticsParams{
+		URI:         req.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	}
+	return nil, s.publish("textDocument/publishDiagnostics", diag)
+}
+
+func (s *server) update(uri, text string) error {
+	f := parseFile(uri, text)
+	s.files[uri] = f
+	diag := PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: f.Diagnostics,
+	}
+	return s.publish("textDocument/publishDiagnostics", diag)
+}
+
+func (s *server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var req DocumentSymbolParams
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	f, ok := s.files[req.TextDocument.URI]
+	if !ok {
+		return []DocumentSymbol{}, nil
+	}
+	return f.symbols(), nil
+}
+
+func (s *server) hover(params json.RawMessage) (interface{}, error) {
+	var req TextDocumentPositionParams
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	f, ok := s.files[req.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	return f.hover(req.Position), nil
+}
+
+func (s *server) definition(params json.RawMessage) (interface{}, error) {
+	var req TextDocumentPositionParams
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	f, ok := s.files[req.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	return f.definition(req.Position), nil
+}
+
+func (s *server) completion(params json.RawMessage) (interface{}, error) {
+	var req TextDocumentPositionParams
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	f, ok := s.files[req.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	return f.complete(req.Position, s.macros), nil
+}
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return &rpcError{Code: codeInvalidParams, Message: "params missing"}
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
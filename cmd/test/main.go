@@ -44,17 +44,9 @@ func main() {
 
 func decodeFile(r io.Reader) error {
 	var (
-		dat  = make(map[string]interface{})
-		dec  = fig.NewDecoder(r)
-		fmap = fig.FuncMap{
-			"repeat": strings.Repeat,
-			"upper":  strings.ToUpper,
-			"lower":  strings.ToLower,
-			"join":   strings.Join,
-			"uuid3":  func(str string) string { return str },
-		}
+		dat = make(map[string]interface{})
+		dec = fig.NewDecoder(r)
 	)
-	dec.Funcs(fmap)
 	dec.Define("env", "dev")
 	dec.Define("lang", "en")
 	if err := dec.Decode(&dat); err != nil {
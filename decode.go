@@ -1,14 +1,15 @@
 package fig
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"net/netip"
 	"net/url"
 	"reflect"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -25,9 +26,18 @@ type FuncMap map[string]interface{}
 
 type Decoder struct {
 	read    io.Reader
+	fsys    fs.FS
+	file    string
+	schemes map[string]SchemeHandler
+	macros  map[string]customMacro
+	policy  *Policy
 	fmap    FuncMap
 	options *Env
 	locals  *Env
+	schema  *Schema
+	builtin bool
+	strict  bool
+	limit   int
 }
 
 func NewDecoder(r io.Reader) *Decoder {
@@ -36,6 +46,45 @@ func NewDecoder(r io.Reader) *Decoder {
 		fmap:    make(FuncMap),
 		options: EmptyEnv(),
 		locals:  EmptyEnv(),
+		builtin: true,
+		limit:   maxIncludeDepth,
+	}
+}
+
+func NewDecoderFS(fsys fs.FS, file string) *Decoder {
+	d := NewDecoder(nil)
+	d.fsys = fsys
+	d.file = file
+	return d
+}
+
+func (d *Decoder) UseFS(fsys fs.FS) {
+	d.fsys = fsys
+}
+
+func (d *Decoder) UsePolicy(pol Policy) {
+	d.policy = &pol
+}
+
+func (d *Decoder) RegisterScheme(scheme string, handler SchemeHandler) {
+	if d.schemes == nil {
+		d.schemes = make(map[string]SchemeHandler)
+	}
+	d.schemes[scheme] = handler
+}
+
+type customMacro struct {
+	fn   MacroFunc
+	with bool
+}
+
+func (d *Decoder) RegisterMacro(name string, fn MacroFunc, withObject bool) {
+	if d.macros == nil {
+		d.macros = make(map[string]customMacro)
+	}
+	d.macros[name] = customMacro{
+		fn:   fn,
+		with: withObject,
 	}
 }
 
@@ -49,11 +98,83 @@ func (d *Decoder) Funcs(set FuncMap) {
 	}
 }
 
+func (d *Decoder) DisableBuiltins() {
+	d.builtin = false
+}
+
+func (d *Decoder) MaxIncludeDepth(depth int) {
+	d.limit = depth
+}
+
+func (d *Decoder) DisallowUnknownFields() {
+	d.strict = true
+}
+
+func (d *Decoder) UseSchema(s *Schema) {
+	d.schema = s
+}
+
 func (d *Decoder) Decode(v interface{}) error {
-	n, err := ParseWithEnv(d.read, d.locals)
+	p, err := d.parser()
 	if err != nil {
 		return err
 	}
+	n, err := p.Parse()
+	if err != nil {
+		return err
+	}
+	if d.schema != nil {
+		if err := d.schema.validate(n, d.locals); err != nil {
+			return err
+		}
+	}
+	err = d.decodeRoot(n, v)
+	var de *DecodeError
+	if errors.As(err, &de) {
+		de.File = p.file
+		de.Snippet = makeSnippet(p.scan.input, de.Line, de.Column)
+	}
+	return err
+}
+
+func (d *Decoder) parser() (*Parser, error) {
+	var (
+		p   *Parser
+		err error
+	)
+	if d.read == nil && d.fsys != nil {
+		p, err = NewParserFS(d.fsys, d.file)
+	} else if p, err = NewParser(d.read); err == nil && d.fsys != nil {
+		p.UseFS(d.fsys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for k, h := range d.schemes {
+		p.RegisterScheme(k, h)
+	}
+	for k, m := range d.macros {
+		p.RegisterMacro(k, m.fn, m.with)
+	}
+	if d.policy != nil {
+		p.UsePolicy(*d.policy)
+	}
+	p.MaxIncludeDepth(d.limit)
+	d.locals.funcs = make(FuncMap)
+	if d.builtin {
+		for k, fn := range builtins {
+			d.locals.funcs[k] = fn
+		}
+	}
+	for k, fn := range d.fmap {
+		d.locals.funcs[k] = fn
+	}
+	p.env = d.locals
+	return p, nil
+}
+
+func (d *Decoder) decodeRoot(n Node, v interface{}) error {
+	var err error
 	value := reflect.ValueOf(v)
 	if value.Kind() != reflect.Ptr || value.IsNil() {
 		return fmt.Errorf("expecting not nil ptr")
@@ -87,11 +208,21 @@ func (d *Decoder) Decode(v interface{}) error {
 }
 
 func (d *Decoder) registerObject(obj *object) error {
+	var exprs []*option
 	for _, n := range obj.Nodes {
 		o, ok := n.(*option)
 		if !ok {
 			continue
 		}
+		switch o.Value.(type) {
+		case *variable, *call:
+			exprs = append(exprs, o)
+			continue
+		}
+		if isExpr(o.Value) {
+			exprs = append(exprs, o)
+			continue
+		}
 		if t, ok := o.Value.(*template); ok {
 			var err error
 			o.Value, err = d.decodeTemplate(t)
@@ -103,6 +234,31 @@ func (d *Decoder) registerObject(obj *object) error {
 			d.options.define(o.Ident, val)
 		}
 	}
+	return d.registerExprs(obj, exprs)
+}
+
+func (d *Decoder) registerExprs(obj *object, list []*option) error {
+	for len(list) > 0 {
+		var (
+			rest []*option
+			err  error
+		)
+		for _, o := range list {
+			val, err1 := eval(o.Value, d)
+			if err1 != nil {
+				if err == nil {
+					err = decodeError(joinPath(objectPath(obj), o.Ident), o.Pos(), err1)
+				}
+				rest = append(rest, o)
+				continue
+			}
+			d.options.define(o.Ident, val)
+		}
+		if len(rest) == len(list) {
+			return err
+		}
+		list = rest
+	}
 	return nil
 }
 
@@ -116,7 +272,9 @@ func (d *Decoder) decode(n Node, value reflect.Value) error {
 		}
 		err = d.decodeArray(n, value)
 	case *object:
-		d.registerObject(n)
+		if err = d.registerObject(n); err != nil {
+			break
+		}
 		err = d.decodeObject(n, value)
 	case *option:
 		err = d.decodeOption(n, value)
@@ -126,6 +284,8 @@ func (d *Decoder) decode(n Node, value reflect.Value) error {
 		err = d.decodeCall(n, value)
 	case *variable:
 		err = d.decodeVariable(n, value)
+	case *unary, *binary, *ternary:
+		err = d.decodeExpr(n, value)
 	default:
 		err = fmt.Errorf("value (%s) can not be decoded from %T", value.Kind(), n)
 	}
@@ -250,6 +410,9 @@ func (d *Decoder) decodeLiteral(lit *literal, v reflect.Value) error {
 }
 
 func (d *Decoder) decodeSlice(slc *slice, v reflect.Value) error {
+	if slc.IsIndex() {
+		return d.decodeExpr(slc, v)
+	}
 	if err := d.decode(slc.Node, v); err != nil {
 		return err
 	}
@@ -260,17 +423,6 @@ func (d *Decoder) decodeSlice(slc *slice, v reflect.Value) error {
 	if !isArray(arr) || arr.Len() == 0 {
 		return fmt.Errorf("%s can not be sliced", v.Type())
 	}
-	if slc.IsIndex() {
-		from := slc.From()
-		if from < 0 {
-			from = arr.Len() + from
-		}
-		if from >= arr.Len() || from < 0 {
-			return fmt.Errorf("index out of range (%d >= %d)", slc.from.index, v.Len())
-		}
-		v.Set(arr.Index(from))
-		return nil
-	}
 	if slc.IsCopy() {
 		var (
 			s = reflect.SliceOf(arr.Type().Elem())
@@ -311,33 +463,11 @@ func (d *Decoder) decodeSlice(slc *slice, v reflect.Value) error {
 }
 
 func (d *Decoder) decodeTemplate(tpl *template) (Node, error) {
-	var str strings.Builder
-	for _, n := range tpl.Nodes {
-		switch n.Type() {
-		case TypeLiteral:
-			s, _ := n.(*literal).GetString()
-			str.WriteString(s)
-		case TypeVariable:
-			val, err := d.resolveVariable(n.(*variable))
-			if err != nil {
-				return nil, err
-			}
-			switch v := val.(type) {
-			case string:
-				str.WriteString(v)
-			case int64:
-				str.WriteString(strconv.FormatInt(v, 10))
-			case float64:
-				str.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
-			case bool:
-				str.WriteString(strconv.FormatBool(v))
-			default:
-			}
-		default:
-			return nil, fmt.Errorf("unexpected node type")
-		}
+	str, err := evalTemplate(tpl, d)
+	if err != nil {
+		return nil, err
 	}
-	return createLiteralFromString(str.String()), nil
+	return createLiteralFromString(str), nil
 }
 
 func (d *Decoder) resolveVariable(ident *variable) (interface{}, error) {
@@ -358,16 +488,78 @@ func (d *Decoder) decodeVariable(ident *variable, v reflect.Value) error {
 	if err != nil {
 		return err
 	}
+	if err := setValue(val, v); err != nil {
+		return fmt.Errorf("%s: %w", ident.Name(), err)
+	}
+	return nil
+}
+
+func (d *Decoder) decodeExpr(n Node, v reflect.Value) error {
+	val, err := eval(n, d)
+	if err != nil {
+		return err
+	}
+	return setValue(val, v)
+}
+
+func setValue(val interface{}, v reflect.Value) error {
+	if val == nil {
+		return nil
+	}
 	var (
 		value = reflect.ValueOf(val)
 		typ   = value.Type()
 	)
-	if typ.AssignableTo(v.Type()) {
+	switch {
+	case typ.AssignableTo(v.Type()):
 		v.Set(value)
-	} else if typ.ConvertibleTo(v.Type()) {
+	case v.Kind() == reflect.String && typ.Kind() != reflect.String:
+		v.SetString(toString(val))
+	case v.Kind() == reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(val, v.Elem())
+	case isArray(value) && v.Kind() == reflect.Slice:
+		vs := reflect.MakeSlice(v.Type(), 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			vf := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(value.Index(i).Interface(), vf); err != nil {
+				return err
+			}
+			vs = reflect.Append(vs, vf)
+		}
+		v.Set(vs)
+	case value.Kind() == reflect.Map && v.Kind() == reflect.Map:
+		vs := reflect.MakeMapWithSize(v.Type(), value.Len())
+		for _, k := range value.MapKeys() {
+			var (
+				vk = reflect.New(v.Type().Key()).Elem()
+				vf = reflect.New(v.Type().Elem()).Elem()
+			)
+			if err := setValue(k.Interface(), vk); err != nil {
+				return err
+			}
+			if err := setValue(value.MapIndex(k).Interface(), vf); err != nil {
+				return err
+			}
+			vs.SetMapIndex(vk, vf)
+		}
+		v.Set(vs)
+	case value.Kind() == reflect.Map && v.Kind() == reflect.Struct:
+		for _, k := range value.MapKeys() {
+			x, ok := lookupField(v.Type(), toString(k.Interface()))
+			if !ok {
+				continue
+			}
+			if err := setValue(value.MapIndex(k).Interface(), v.Field(x)); err != nil {
+				return err
+			}
+		}
+	case typ.ConvertibleTo(v.Type()):
 		v.Set(value.Convert(v.Type()))
-	} else {
-		return fmt.Errorf("%s: %s can not be assigned to %s", ident.Name(), typ, v.Type())
+	default:
+		return fmt.Errorf("%s can not be assigned to %s", typ, v.Type())
 	}
 	return nil
 }
@@ -407,6 +599,8 @@ func (d *Decoder) decodeOption(opt *option, v reflect.Value) error {
 		err = d.decodeVariable(opt.Value.(*variable), v)
 	case TypeSlice:
 		err = d.decodeSlice(opt.Value.(*slice), v)
+	case TypeExpr:
+		err = d.decodeExpr(opt.Value, v)
 	default:
 		err = fmt.Errorf("literal/array/slice expected!")
 	}
@@ -419,41 +613,247 @@ func (d *Decoder) decodeOption(opt *option, v reflect.Value) error {
 var errtype = reflect.TypeOf((*error)(nil)).Elem()
 
 func (d *Decoder) decodeCall(c *call, v reflect.Value) error {
-	call := reflect.ValueOf(d.fmap[c.Ident])
+	val, err := eval(c, d)
+	if err != nil {
+		return err
+	}
+	return setValue(val, v)
+}
+
+func (d *Decoder) callFunction(c *call, ev evaluator) (interface{}, error) {
+	fn, ok := d.locals.function(c.Ident)
+	if !ok {
+		return nil, fmt.Errorf("%s: undefined function", c.Ident)
+	}
+	return callNative(fn, c, ev)
+}
+
+func callNative(fn interface{}, c *call, ev evaluator) (interface{}, error) {
+	call := reflect.ValueOf(fn)
 	if call.Kind() != reflect.Func {
-		return fmt.Errorf("%s: undefined function", c.Ident)
+		return nil, fmt.Errorf("%s: undefined function", c.Ident)
 	}
 	var (
-		typ  = call.Type()
-		nin  = typ.NumIn()
-		nout = typ.NumOut()
-		args []reflect.Value
+		typ   = call.Type()
+		nin   = typ.NumIn()
+		nout  = typ.NumOut()
+		fixed = nin
+		opts  reflect.Value
+		args  []reflect.Value
 	)
-	if nout == 0 || nout > 2 || nin != len(c.Args) {
-		return fmt.Errorf("%s: invalid function signature ", c.Ident)
+	if nout == 0 || nout > 2 {
+		return nil, fmt.Errorf("%s: invalid function signature ", c.Ident)
+	}
+	switch {
+	case typ.IsVariadic():
+		fixed--
+	case nin > 0 && isOptions(typ.In(nin-1)):
+		if len(c.Args) == nin && len(c.Kwargs) == 0 {
+			break
+		}
+		fixed--
+		opts = reflect.New(typ.In(fixed)).Elem()
 	}
-	for i := 0; i < nin; i++ {
-		f := reflect.New(typ.In(i)).Elem()
-		if err := d.decode(c.Args[i], f); err != nil {
-			return err
+	if len(c.Args) < fixed {
+		return nil, fmt.Errorf("%s: not enough arguments given", c.Ident)
+	}
+	if len(c.Kwargs) > 0 && !opts.IsValid() {
+		return nil, fmt.Errorf("%s: keyword arguments not supported", c.Ident)
+	}
+	var set []int
+	for i, n := range c.Args {
+		val, err := eval(n, ev)
+		if err != nil {
+			return nil, err
+		}
+		var f reflect.Value
+		switch {
+		case i < fixed:
+			f = reflect.New(typ.In(i)).Elem()
+		case typ.IsVariadic():
+			f = reflect.New(typ.In(fixed).Elem()).Elem()
+		case opts.Kind() == reflect.Struct:
+			x := nthField(opts.Type(), i-fixed)
+			if x < 0 {
+				return nil, fmt.Errorf("%s: too many arguments given", c.Ident)
+			}
+			set = append(set, x)
+			f = opts.Field(x)
+		default:
+			return nil, fmt.Errorf("%s: too many arguments given", c.Ident)
+		}
+		if err := setValue(val, f); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Ident, err)
+		}
+		if i < fixed || typ.IsVariadic() {
+			args = append(args, f)
 		}
-		args = append(args, f)
+	}
+	if opts.Kind() == reflect.Map {
+		opts.Set(reflect.MakeMap(opts.Type()))
+	}
+	for _, k := range c.Keys() {
+		val, err := eval(c.Kwargs[k], ev)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Kind() == reflect.Map {
+			f := reflect.New(opts.Type().Elem()).Elem()
+			if err := setValue(val, f); err != nil {
+				return nil, fmt.Errorf("%s: %w", c.Ident, err)
+			}
+			opts.SetMapIndex(reflect.ValueOf(k).Convert(opts.Type().Key()), f)
+			continue
+		}
+		x, ok := lookupField(opts.Type(), k)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown parameter %s", c.Ident, k)
+		}
+		for _, i := range set {
+			if i == x {
+				return nil, fmt.Errorf("%s: parameter %s given as positional and keyword", c.Ident, k)
+			}
+		}
+		if err := setValue(val, opts.Field(x)); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Ident, err)
+		}
+	}
+	if opts.IsValid() {
+		args = append(args, opts)
 	}
 	ret := call.Call(args)
 	if len(ret) == 2 {
 		if ret[1].Type() != errtype {
-			return fmt.Errorf("return value should be of type error")
+			return nil, fmt.Errorf("return value should be of type error")
 		}
 		err, _ := ret[1].Interface().(error)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("%s: %w", c.Ident, err)
 		}
 	}
-	if !ret[0].Type().AssignableTo(v.Type()) && !ret[0].Type().ConvertibleTo(v.Type()) {
-		return fmt.Errorf("return value can not be assigned to %s", v.Type())
+	return ret[0].Interface(), nil
+}
+
+func isOptions(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		return !isSpecial(t)
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String
+	default:
+		return false
 	}
-	v.Set(ret[0].Convert(v.Type()))
-	return nil
+}
+
+func nthField(t reflect.Type, n int) int {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := parseTag(f); !ok || f.PkgPath != "" {
+			continue
+		}
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return -1
+}
+
+func lookupField(t reflect.Type, ident string) (int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag, ok := parseTag(f)
+		if !ok {
+			continue
+		}
+		if tag.Name == ident || (!tag.Renamed && strings.ToLower(tag.Name) == ident) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+type fieldTag struct {
+	Name       string
+	Renamed    bool
+	Required   bool
+	OmitEmpty  bool
+	Default    string
+	HasDefault bool
+}
+
+const defaultPrefix = "default="
+
+func parseTag(f reflect.StructField) (fieldTag, bool) {
+	var (
+		tag = fieldTag{Name: f.Name}
+		str = f.Tag.Get("fig")
+	)
+	if str == "-" {
+		return tag, false
+	}
+	parts := splitTag(str)
+	if parts[0] != "" && !strings.HasPrefix(parts[0], defaultPrefix) {
+		tag.Name, tag.Renamed = parts[0], true
+		parts = parts[1:]
+	}
+	for _, p := range parts {
+		switch {
+		case p == "required":
+			tag.Required = true
+		case p == "omitempty":
+			tag.OmitEmpty = true
+		case strings.HasPrefix(p, defaultPrefix):
+			tag.Default, tag.HasDefault = strings.TrimPrefix(p, defaultPrefix), true
+		}
+	}
+	return tag, true
+}
+
+func splitTag(str string) []string {
+	var (
+		parts []string
+		depth int
+		quote rune
+		last  int
+	)
+	for i, r := range str {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case isQuote(r) || isBacktick(r):
+			quote = r
+		case r == '[' || r == '(':
+			depth++
+		case r == ']' || r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, str[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, str[last:])
+}
+
+func parseDefault(str string) (Node, error) {
+	n, err := Parse(strings.NewReader("default = " + str))
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := n.(*object)
+	if !ok {
+		return nil, fmt.Errorf("root node is not an object")
+	}
+	node, ok := obj.take("default")
+	if !ok {
+		return nil, fmt.Errorf("value expected")
+	}
+	return node, nil
 }
 
 func (d *Decoder) decodeArrayFromInterface(n Node, v reflect.Value) (bool, error) {
@@ -518,46 +918,90 @@ func (d *Decoder) decodeObject(obj *object, v reflect.Value) error {
 	d.push()
 	defer d.pop()
 
-	t := v.Type()
+	var (
+		t    = v.Type()
+		seen = make(map[string]struct{})
+	)
 	for i := 0; i < v.NumField(); i++ {
 		f := v.Field(i)
 		if !f.CanSet() {
 			continue
 		}
-		var (
-			ft  = t.Field(i)
-			tag = ft.Name
-		)
-		switch v := ft.Tag.Get("fig"); v {
-		case "":
-		case "-":
+		ft := t.Field(i)
+		tag, ok := parseTag(ft)
+		if !ok {
 			continue
-		default:
-			tag = v
 		}
-		node, ok := obj.take(tag)
-		if !ok && tag == ft.Name {
-			node, ok = obj.take(strings.ToLower(tag))
-			if !ok {
+		name := tag.Name
+		node, ok := obj.take(name)
+		if !ok && !tag.Renamed {
+			name = strings.ToLower(name)
+			node, ok = obj.take(name)
+		}
+		if !ok {
+			switch {
+			case tag.Required:
+				return decodeError(joinPath(objectPath(obj), name), obj.pos, fmt.Errorf("required field missing"))
+			case tag.HasDefault:
+				n, err := parseDefault(tag.Default)
+				if err != nil {
+					return decodeError(joinPath(objectPath(obj), name), obj.pos, fmt.Errorf("invalid default value: %w", err))
+				}
+				node = n
+			default:
 				continue
 			}
 		}
+		seen[name] = struct{}{}
 		if node == nil {
 			continue
 		}
-		if ok, err := d.decodeSpecial(f, node); ok {
-			if err != nil {
-				return err
-			}
+		var err error
+		if ok, err = d.decodeSpecial(f, node); !ok {
+			err = d.decode(node, f)
+		}
+		if err != nil {
+			return decodeError(joinPath(objectPath(obj), name), node.Pos(), err)
+		}
+	}
+	if d.strict {
+		return checkUnknown(obj, seen)
+	}
+	return nil
+}
+
+func checkUnknown(obj *object, seen map[string]struct{}) error {
+	for i, n := range obj.Nodes {
+		if n.Type() == TypeComment {
 			continue
 		}
-		if err := d.decode(node, f); err != nil {
-			return err
+		name := obj.Revex[i]
+		if _, ok := seen[name]; ok {
+			continue
 		}
+		return decodeError(joinPath(objectPath(obj), name), n.Pos(), fmt.Errorf("unknown field"))
 	}
 	return nil
 }
 
+func objectPath(obj *object) string {
+	if obj == nil || obj.parent == nil {
+		return ""
+	}
+	name := obj.Name
+	if arr, ok := obj.parent.take(obj.Name); ok {
+		if arr, ok := arr.(*array); ok {
+			for i, n := range arr.Nodes {
+				if n == obj {
+					name = fmt.Sprintf("%s[%d]", name, i)
+					break
+				}
+			}
+		}
+	}
+	return joinPath(objectPath(obj.parent), name)
+}
+
 func (d *Decoder) decodeMap(obj *object, v reflect.Value) error {
 	key := v.Type().Key()
 	if k := key.Kind(); k != reflect.String {
@@ -589,11 +1033,13 @@ func (d *Decoder) decodeMap(obj *object, v reflect.Value) error {
 			)
 			vf = reflect.New(f.Type()).Elem()
 			err = d.decodeArray(o, vf)
+		case *note:
+			continue
 		default:
 			err = fmt.Errorf("%s: can not decode %T", obj.Revex[i], o)
 		}
 		if err != nil {
-			return err
+			return decodeError(joinPath(objectPath(obj), obj.Revex[i]), o.Pos(), err)
 		}
 		v.SetMapIndex(reflect.ValueOf(obj.Revex[i]), vf)
 	}
@@ -614,6 +1060,8 @@ func (d *Decoder) decodeSpecial(v reflect.Value, n Node) (bool, error) {
 		err = d.decodeRegex(v, n)
 	case t == iptype:
 		err = d.decodeIP(v, n)
+	case t == durationtype:
+		err = d.decodeDuration(v, n)
 	default:
 		nok = true
 	}
@@ -650,6 +1098,50 @@ func (d *Decoder) decodeTime(v reflect.Value, n Node) error {
 	return err
 }
 
+func (d *Decoder) decodeDuration(v reflect.Value, n Node) error {
+	opt, ok := n.(*option)
+	if !ok {
+		return fmt.Errorf("decoding duration: option expected")
+	}
+	var (
+		dur time.Duration
+		err error
+	)
+	if lit, ok := opt.Value.(*literal); ok {
+		switch lit.Token.Type {
+		case String, Heredoc, Ident:
+			dur, err = time.ParseDuration(lit.Token.Literal)
+		default:
+			var secs float64
+			secs, err = lit.GetFloat()
+			dur = time.Duration(secs * float64(time.Second))
+		}
+	} else {
+		var val interface{}
+		if val, err = eval(opt.Value, d); err == nil {
+			dur, err = toDuration(val)
+		}
+	}
+	if err == nil {
+		v.SetInt(int64(dur))
+	}
+	return err
+}
+
+func toDuration(val interface{}) (time.Duration, error) {
+	switch v := val.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		return time.ParseDuration(v)
+	}
+	secs, ok := toFloat(val)
+	if !ok {
+		return 0, fmt.Errorf("duration expected! got %T", val)
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
 func (d *Decoder) decodeURL(v reflect.Value, n Node) error {
 	opt, ok := n.(*option)
 	if !ok {
@@ -736,6 +1228,7 @@ var (
 	settertype   = reflect.TypeOf((*Setter)(nil)).Elem()
 	updatetype   = reflect.TypeOf((*Updater)(nil)).Elem()
 	timetype     = reflect.TypeOf((*time.Time)(nil)).Elem()
+	durationtype = reflect.TypeOf((*time.Duration)(nil)).Elem()
 	urltype      = reflect.TypeOf((*url.URL)(nil)).Elem()
 	regextype    = reflect.TypeOf((*regexp.Regexp)(nil)).Elem()
 	iptype       = reflect.TypeOf((*net.IP)(nil)).Elem()
@@ -1,8 +1,12 @@
 package fig_test
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/midbel/fig"
@@ -86,7 +90,7 @@ server {
 	// {Email:midbel@midbel.org Admin:true TTL:100 Meta:map[tracker:redmine vcs:git version:1.0.1] Rule:{TCP:[{List:[80 443 22] Action:allow Disable:false}] UDP:[{List:[80 443 22] Action:block Disable:false}]} Servers:[{Addr:192.168.67.181 Host:ALPHA Back:[10.100.0.1 10.100.0.2]} {Addr:192.168.67.236 Host:OMEGA Back:[10.101.0.1 10.101.0.2 10.101.0.3]}]}
 }
 
-func ExampleDecode_Generic() {
+func ExampleDecode_generic() {
 	const demo = `
 name = demo
 server {
@@ -122,7 +126,7 @@ server {
 	// map[name:demo server:[map[addr:192.168.67.181 enable:false name:alpha ttl:100] map[addr:192.168.67.236 enable:true name:alpha ttl:100]]]
 }
 
-func ExampleDecode_Variables() {
+func ExampleDecode_variables() {
 	const demo = `
 name = demo
 ttl  = 30m
@@ -152,7 +156,7 @@ server {
 	// map[addr:192.168.67.181 name:demo server:[map[addr:192.168.67.181 enable:false name:alpha ttl:1800] map[addr:192.168.67.181 enable:true name:alpha ttl:1800]] ttl:1800]
 }
 
-func ExampleDecode_Special() {
+func ExampleDecode_special() {
 	const demo = `
 when = "2022-01-28"
 	`
@@ -178,7 +182,7 @@ func (s *Settable) Set(str string) error {
 	return nil
 }
 
-func ExampleDecode_Setter() {
+func ExampleDecode_setter() {
 	const demo = `
 set1 = foo
 set2 = bar
@@ -199,7 +203,7 @@ set2 = bar
 	// bar
 }
 
-func ExampleDecoder_Template() {
+func ExampleDecoder_template() {
 	const demo = `
 arg1 = foo
 arg2 = bar
@@ -227,3 +231,456 @@ cmd3 = %s
 	// echo bar
 	// echo bar/foo
 }
+
+func ExampleDecoder_Decode_expression() {
+	const demo = `
+base    = 10
+timeout = $base * 2 + 30s
+ratio   = $base / 4.0
+name    = "srv-" + @env
+debug   = @env == "dev" ? true : false
+ports   = [$base * 8, ($base - 2) * 100 % 443]
+	`
+	c := struct {
+		Timeout int
+		Ratio   float64
+		Name    string
+		Debug   bool
+		Ports   []int
+	}{}
+	dec := fig.NewDecoder(strings.NewReader(demo))
+	dec.Define("env", "dev")
+	if err := dec.Decode(&c); err != nil {
+		fmt.Printf("unexpected error decoding demo (expression): %s\n", err)
+		return
+	}
+	fmt.Printf("%+v\n", c)
+	// Output:
+	// {Timeout:50 Ratio:2.5 Name:srv-dev Debug:true Ports:[80 357]}
+}
+
+func ExampleDecoder_Decode_functions() {
+	const demo = `
+greet(name, polite=false) {
+  $polite ? "hello " + $name : "hi " + $name
+}
+user  = bob
+msg1  = greet($user)
+msg2  = greet($user, polite=true)
+shout = upper(greet(name=alice))
+	`
+	c := struct {
+		Msg1  string
+		Msg2  string
+		Shout string
+	}{}
+	dec := fig.NewDecoder(strings.NewReader(demo))
+	dec.Funcs(fig.FuncMap{
+		"upper": strings.ToUpper,
+	})
+	if err := dec.Decode(&c); err != nil {
+		fmt.Printf("unexpected error decoding demo (functions): %s\n", err)
+		return
+	}
+	fmt.Println(c.Msg1)
+	fmt.Println(c.Msg2)
+	fmt.Println(c.Shout)
+	// Output:
+	// hi bob
+	// hello bob
+	// HI ALICE
+}
+
+func ExampleDecoder_Decode_builtins() {
+	const demo = `
+name  = "fig"
+tags  = sort(unique([web, db, web, cache]))
+id    = uuid5(dns, $name + ".org")
+label = format("v%03d", len($tags)) + "-" + upper($name)
+	`
+	c := struct {
+		Tags  []string
+		ID    string `fig:"id"`
+		Label string
+	}{}
+	if err := fig.NewDecoder(strings.NewReader(demo)).Decode(&c); err != nil {
+		fmt.Printf("unexpected error decoding demo (builtins): %s\n", err)
+		return
+	}
+	fmt.Printf("%+v\n", c)
+	// Output:
+	// {Tags:[cache db web] ID:57ad109a-4978-5382-942a-26ad41461e8f Label:v003-FIG}
+}
+
+func ExampleDecoder_Decode_native() {
+	const demo = `
+label  = join([web, db, cache], sep="|")
+greet  = concat("hello", " ", "world")
+banner = repeat("=", n=3)
+server = endpoint("localhost", 8080)
+	`
+	type Server struct {
+		Addr string
+		Port int
+	}
+	c := struct {
+		Label  string
+		Greet  string
+		Banner string
+		Server Server
+	}{}
+	dec := fig.NewDecoder(strings.NewReader(demo))
+	dec.Funcs(fig.FuncMap{
+		"concat": func(values ...string) string {
+			return strings.Join(values, "")
+		},
+		"repeat": func(str string, opts struct {
+			Sep   string
+			Count int `fig:"n"`
+		}) string {
+			return strings.Repeat(str+opts.Sep, opts.Count)
+		},
+		"endpoint": func(addr string, port int) map[string]interface{} {
+			return map[string]interface{}{"addr": addr, "port": port}
+		},
+	})
+	if err := dec.Decode(&c); err != nil {
+		fmt.Printf("unexpected error decoding demo (native): %s\n", err)
+		return
+	}
+	fmt.Printf("%+v\n", c)
+	// Output:
+	// {Label:web|db|cache Greet:hello world Banner:=== Server:{Addr:localhost Port:8080}}
+}
+
+func ExampleDecoder_Decode_tags() {
+	const demo = `
+host = "192.168.67.181"
+	`
+	c := struct {
+		Host    string        `fig:"host,required"`
+		Port    int           `fig:"port,default=8080"`
+		Timeout time.Duration `fig:"timeout,default=30s"`
+		Size    int64         `fig:"size,default=10Kb"`
+	}{}
+	if err := fig.NewDecoder(strings.NewReader(demo)).Decode(&c); err != nil {
+		fmt.Printf("unexpected error decoding demo (tags): %s\n", err)
+		return
+	}
+	fmt.Printf("%+v\n", c)
+
+	err := fig.NewDecoder(strings.NewReader("port = 80")).Decode(&c)
+	fmt.Println(err)
+	// Output:
+	// {Host:192.168.67.181 Port:8080 Timeout:30s Size:10240}
+	// host: required field missing
+}
+
+func ExampleDecoder_DisallowUnknownFields() {
+	const demo = `
+hostname = "alpha"
+server {
+  addr = "192.168.67.181"
+}
+server {
+  addr = "192.168.67.236"
+  prot = 80
+}
+	`
+	c := struct {
+		Hostname string
+		Server   []struct {
+			Addr string
+			Port int
+		}
+	}{}
+	dec := fig.NewDecoder(strings.NewReader(demo))
+	dec.DisallowUnknownFields()
+	fmt.Println(dec.Decode(&c))
+	// Output:
+	// 8:3: server[1].prot: unknown field
+}
+
+func ExampleNewDecoderFS() {
+	fsys := fstest.MapFS{
+		"etc/app.fig": {
+			Data: []byte(".include(\"conf.d\", name=server, method=append, fatal=true)\n.readfile(\"motd.txt\", name=motd)\n"),
+		},
+		"etc/motd.txt":           {Data: []byte("welcome")},
+		"etc/conf.d/alpha.fig":   {Data: []byte("addr = alpha\n.include(\"../port.fig\", fatal=true)\n")},
+		"etc/conf.d/omega.fig":   {Data: []byte("addr = omega\n.include(\"/etc/port.fig\", fatal=true)\n")},
+		"etc/conf.d/ignored.txt": {Data: []byte("not a fig file")},
+		"etc/port.fig":           {Data: []byte("port = 8080\n")},
+	}
+	var cfg struct {
+		Motd   string
+		Server []struct {
+			Addr string
+			Port int
+		}
+	}
+	if err := fig.NewDecoderFS(fsys, "etc/app.fig").Decode(&cfg); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(cfg.Motd)
+	for _, s := range cfg.Server {
+		fmt.Printf("%s:%d\n", s.Addr, s.Port)
+	}
+	// Output:
+	// welcome
+	// alpha:8080
+	// omega:8080
+}
+
+func ExampleDecoder_RegisterMacro() {
+	const demo = `
+.listen(8443, tls=true)
+.feature(metrics) {
+	enabled = true
+}
+`
+	var cfg struct {
+		Port    int
+		Scheme  string
+		Enabled bool
+		Feature string
+	}
+	dec := fig.NewDecoder(strings.NewReader(demo))
+	dec.RegisterMacro("listen", func(ctx *fig.MacroContext) error {
+		port, err := ctx.GetInt(0, "port")
+		if err != nil {
+			return err
+		}
+		scheme := "http"
+		if ok, _ := ctx.GetBool(1, "tls"); ok {
+			scheme = "https"
+		}
+		if err := ctx.Set("port", port); err != nil {
+			return err
+		}
+		return ctx.Set("scheme", scheme)
+	}, false)
+	dec.RegisterMacro("feature", func(ctx *fig.MacroContext) error {
+		name, err := ctx.GetString(0, "name")
+		if err != nil {
+			return err
+		}
+		if err := ctx.Set("feature", name); err != nil {
+			return err
+		}
+		return ctx.Merge(ctx.Block())
+	}, true)
+	if err := dec.Decode(&cfg); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%s:%d %s=%t\n", cfg.Scheme, cfg.Port, cfg.Feature, cfg.Enabled)
+	// Output:
+	// https:8443 metrics=true
+}
+
+func ExampleNewDecoderFS_json() {
+	fsys := fstest.MapFS{
+		"main.fig": {Data: []byte("name = demo\n.include(\"port.fig\", fatal=true)\n")},
+		"port.fig": {Data: []byte("port = 8080\n")},
+	}
+	if err := fig.NewDecoderFS(fsys, "main.fig").DecodeJSON(os.Stdout); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// {
+	//   "name": "demo",
+	//   "port": 8080
+	// }
+}
+
+func TestBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		Input string
+		Want  string
+	}{
+		{Input: `a = repeat("x", -1)`, Want: "repeat: negative count (-1)"},
+		{Input: `a = repeat("x", 1099511627776)`, Want: "repeat: result too large"},
+		{Input: `a = replace(repeat("x", 1024), "", repeat("y", 1024))`, Want: "replace: result too large"},
+		{Input: `a = len(5)`, Want: "len: int64 has no length"},
+	}
+	for _, tt := range tests {
+		var v struct {
+			A string
+		}
+		dec := fig.NewDecoder(strings.NewReader(tt.Input))
+		err := dec.Decode(&v)
+		if err == nil || !strings.Contains(err.Error(), tt.Want) || strings.Count(err.Error(), "len:") > 1 {
+			t.Errorf("%s: want error %q, got %v", tt.Input, tt.Want, err)
+		}
+	}
+}
+
+func TestBuiltinEmptyRepeat(t *testing.T) {
+	v := struct {
+		A string
+	}{A: "x"}
+	if err := fig.NewDecoder(strings.NewReader(`a = repeat("", 5)`)).Decode(&v); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if v.A != "" {
+		t.Errorf("empty string expected! got %q", v.A)
+	}
+}
+
+func TestDecodeDuration(t *testing.T) {
+	const demo = `
+base    = 10
+delay   = "1m30s"
+timeout = $base * 2 + 30s
+other   = $base
+wait    = $delay
+max     = max(1, 2)
+`
+	var c struct {
+		Timeout time.Duration
+		Other   time.Duration
+		Wait    time.Duration
+		Max     time.Duration
+	}
+	dec := fig.NewDecoder(strings.NewReader(demo))
+	if err := dec.Decode(&c); err != nil {
+		t.Fatalf("fail to decode durations: %s", err)
+	}
+	tests := []struct {
+		Field string
+		Got   time.Duration
+		Want  time.Duration
+	}{
+		{Field: "timeout", Got: c.Timeout, Want: 50 * time.Second},
+		{Field: "other", Got: c.Other, Want: 10 * time.Second},
+		{Field: "wait", Got: c.Wait, Want: 90 * time.Second},
+		{Field: "max", Got: c.Max, Want: 2 * time.Second},
+	}
+	for _, tt := range tests {
+		if tt.Got != tt.Want {
+			t.Errorf("%s: want %s, got %s", tt.Field, tt.Want, tt.Got)
+		}
+	}
+}
+
+func TestDecodeExprError(t *testing.T) {
+	var c struct {
+		Y int
+	}
+	err := fig.NewDecoder(strings.NewReader("x = 1 / 0\ny = $x\n")).Decode(&c)
+
+	var de *fig.DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("decode error expected! got %v", err)
+	}
+	if de.Path != "x" || de.Line != 1 || !strings.Contains(de.Err.Error(), "division by zero") {
+		t.Errorf("unexpected error: %s (%s at %d)", de, de.Path, de.Line)
+	}
+}
+
+func TestDecodeSlice(t *testing.T) {
+	const demo = `
+index  = @list[1]
+last   = @list[-1]
+middle = @list[1:3]
+`
+	var c struct {
+		Index  int
+		Last   int
+		Middle []int
+	}
+	dec := fig.NewDecoder(strings.NewReader(demo))
+	dec.Define("list", []int{1, 2, 3, 4})
+	if err := dec.Decode(&c); err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	if c.Index != 2 || c.Last != 4 || len(c.Middle) != 2 || c.Middle[0] != 2 {
+		t.Errorf("unexpected values: %+v", c)
+	}
+}
+
+func TestDecodeFuncs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.fig":  {Data: []byte(".include(file=extra())\na = upper(\"x\")\n")},
+		"extra.fig": {Data: []byte("b = 2\n")},
+	}
+	var c struct {
+		A string
+		B int
+	}
+	dec := fig.NewDecoderFS(fsys, "main.fig")
+	dec.Funcs(fig.FuncMap{
+		"extra": func() string { return "extra.fig" },
+	})
+	if err := dec.Decode(&c); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.A != "X" || c.B != 2 {
+		t.Errorf("unexpected values: %+v", c)
+	}
+
+	for _, doc := range []string{"a = upper(\"x\")\n", ".include(file=upper(\"extra.fig\"))\n"} {
+		dec := fig.NewDecoder(strings.NewReader(doc))
+		dec.DisableBuiltins()
+		err := dec.Decode(&c)
+		if err == nil || !strings.Contains(err.Error(), "upper: undefined function") {
+			t.Errorf("%q: undefined function error expected! got %v", doc, err)
+		}
+	}
+}
+
+func TestDecodeNativeOptions(t *testing.T) {
+	type Point struct {
+		X int
+		Y int
+	}
+	tests := []struct {
+		Input string
+		Want  string
+	}{
+		{Input: `a = draw("p", origin())`, Want: "p(0,1)"},
+		{Input: `a = draw("p", x=2, y=3)`, Want: "p(2,3)"},
+		{Input: `a = draw("p", 4, y=5)`, Want: "p(4,5)"},
+		{Input: `a = draw("p")`, Want: "p(0,0)"},
+	}
+	for _, tt := range tests {
+		var c struct {
+			A string
+		}
+		dec := fig.NewDecoder(strings.NewReader(tt.Input))
+		dec.Funcs(fig.FuncMap{
+			"origin": func() Point {
+				return Point{Y: 1}
+			},
+			"draw": func(name string, pt Point) string {
+				return fmt.Sprintf("%s(%d,%d)", name, pt.X, pt.Y)
+			},
+		})
+		if err := dec.Decode(&c); err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.Input, err)
+			continue
+		}
+		if c.A != tt.Want {
+			t.Errorf("%s: want %s, got %s", tt.Input, tt.Want, c.A)
+		}
+	}
+}
+
+func TestDecodeTagOptions(t *testing.T) {
+	var c struct {
+		Port  int      `fig:"port,default=8080,required"`
+		Hosts []string `fig:"hosts,default=[alpha, beta],omitempty"`
+		Sep   string   `fig:",default=\",\""`
+	}
+	if err := fig.NewDecoder(strings.NewReader("")).Decode(&c); err == nil || !strings.Contains(err.Error(), "port: required field missing") {
+		t.Errorf("required field error expected! got %v", err)
+	}
+	if err := fig.NewDecoder(strings.NewReader("port = 80")).Decode(&c); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Port != 80 || len(c.Hosts) != 2 || c.Hosts[1] != "beta" || c.Sep != "," {
+		t.Errorf("unexpected values: %+v", c)
+	}
+}
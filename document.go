@@ -0,0 +1,905 @@
+package fig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound  = errors.New("path not found")
+	ErrAmbiguous = errors.New("path is ambiguous")
+)
+
+type Document struct {
+	source []byte
+	file   string
+	root   *object
+	tree   *object
+	env    *Env
+	policy *Policy
+}
+
+func ParseDocument(r io.Reader) (*Document, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc := Document{
+		env: EmptyEnv(),
+	}
+	if f, ok := r.(interface{ Name() string }); ok {
+		doc.file = f.Name()
+	}
+	if err := doc.reset(buf); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (d *Document) Define(ident string, value interface{}) {
+	d.env.define(ident, value)
+	d.tree = nil
+}
+
+func (d *Document) UsePolicy(pol Policy) {
+	d.policy = &pol
+	d.tree = nil
+}
+
+func (d *Document) Lookup(path string) (Node, bool) {
+	res, err := d.lookup(path)
+	if err != nil {
+		return nil, false
+	}
+	if res.element >= 0 {
+		arr := res.found[0].node.(*option).Value.(*array)
+		return arr.Nodes[res.element], true
+	}
+	if len(res.found) == 1 {
+		return res.found[0].node, true
+	}
+	arr := createArray()
+	for _, e := range res.found {
+		arr.Append(e.node)
+	}
+	return arr, true
+}
+
+func (d *Document) Get(path string) (interface{}, error) {
+	res, err := d.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	if res.element >= 0 {
+		var (
+			found = res.found[0]
+			arr   = found.node.(*option).Value.(*array)
+		)
+		return eval(arr.Nodes[res.element], docEval{scope: found.parent, env: d.env})
+	}
+	return d.combine(res.found)
+}
+
+func (d *Document) GetString(path string) (string, error) {
+	arg, err := d.argument(path)
+	if err == nil && arg != nil {
+		return arg.GetString()
+	}
+	v, err := d.value(path, err)
+	return toString(v), err
+}
+
+func (d *Document) GetBool(path string) (bool, error) {
+	arg, err := d.argument(path)
+	if err == nil && arg != nil {
+		return arg.GetBool()
+	}
+	v, err := d.value(path, err)
+	if err != nil {
+		return false, err
+	}
+	return toBool(v)
+}
+
+func (d *Document) GetInt(path string) (int64, error) {
+	arg, err := d.argument(path)
+	if err == nil && arg != nil {
+		return arg.GetInt()
+	}
+	v, err := d.value(path, err)
+	if err != nil {
+		return 0, err
+	}
+	if f, ok := toFloat(v); ok {
+		return int64(f), nil
+	}
+	return 0, fmt.Errorf("%s: number expected! got %T", path, v)
+}
+
+func (d *Document) GetUint(path string) (uint64, error) {
+	arg, err := d.argument(path)
+	if err == nil && arg != nil {
+		return arg.GetUint()
+	}
+	v, err := d.value(path, err)
+	if err != nil {
+		return 0, err
+	}
+	if f, ok := toFloat(v); ok && f >= 0 {
+		return uint64(f), nil
+	}
+	return 0, fmt.Errorf("%s: positive number expected! got %v", path, v)
+}
+
+func (d *Document) GetFloat(path string) (float64, error) {
+	arg, err := d.argument(path)
+	if err == nil && arg != nil {
+		return arg.GetFloat()
+	}
+	v, err := d.value(path, err)
+	if err != nil {
+		return 0, err
+	}
+	if f, ok := toFloat(v); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("%s: number expected! got %T", path, v)
+}
+
+func (d *Document) GetDuration(path string) (time.Duration, error) {
+	v, err := d.Get(path)
+	if err != nil {
+		return 0, err
+	}
+	if str, ok := v.(string); ok {
+		return time.ParseDuration(str)
+	}
+	if f, ok := toFloat(v); ok {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	return 0, fmt.Errorf("%s: duration expected! got %T", path, v)
+}
+
+func (d *Document) GetTime(path string) (time.Time, error) {
+	str, err := d.GetString(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, f := range append([]string{time.RFC3339}, timeformat...) {
+		if when, err := time.Parse(f, str); err == nil {
+			return when, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s: invalid time", str)
+}
+
+func (d *Document) GetStringSlice(path string) ([]string, error) {
+	var list []string
+	return list, d.getSlice(path, &list)
+}
+
+func (d *Document) GetIntSlice(path string) ([]int64, error) {
+	var list []int64
+	return list, d.getSlice(path, &list)
+}
+
+func (d *Document) GetFloatSlice(path string) ([]float64, error) {
+	var list []float64
+	return list, d.getSlice(path, &list)
+}
+
+func (d *Document) getSlice(path string, list interface{}) error {
+	v, err := d.Get(path)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	if !isArray(reflect.ValueOf(v)) {
+		v = []interface{}{v}
+	}
+	if err := setValue(v, reflect.ValueOf(list).Elem()); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func (d *Document) argument(path string) (Argument, error) {
+	res, err := d.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	if res.element >= 0 {
+		arr := res.found[0].node.(*option).Value.(*array)
+		lit, _ := arr.Nodes[res.element].(*literal)
+		if lit == nil {
+			return nil, nil
+		}
+		return lit, nil
+	}
+	if len(res.found) > 1 {
+		return nil, fmt.Errorf("%s: %w", path, ErrAmbiguous)
+	}
+	opt, ok := res.found[0].node.(*option)
+	if !ok {
+		return nil, fmt.Errorf("%s: option expected", path)
+	}
+	if _, ok := opt.Value.(*literal); !ok {
+		return nil, nil
+	}
+	return opt, nil
+}
+
+func (d *Document) value(path string, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	return d.Get(path)
+}
+
+func (d *Document) lookup(path string) (*pathResult, error) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	root, err := d.evaluate()
+	if err != nil {
+		return nil, err
+	}
+	res, err := find(root, segs)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.missing) > 0 {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	return res, nil
+}
+
+func (d *Document) combine(list []entry) (interface{}, error) {
+	if len(list) == 1 {
+		return d.valueOf(list[0])
+	}
+	var labeled bool
+	for _, e := range list {
+		if labeled = e.isLabel(); !labeled {
+			break
+		}
+	}
+	if !labeled {
+		var values []interface{}
+		for _, e := range list {
+			v, err := d.valueOf(e)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	return d.group(list)
+}
+
+func (d *Document) group(list []entry) (map[string]interface{}, error) {
+	var (
+		keys   []string
+		groups = make(map[string][]entry)
+	)
+	for _, e := range list {
+		for _, c := range e.children() {
+			k := c.name()
+			if k == "" {
+				continue
+			}
+			if _, ok := groups[k]; !ok {
+				keys = append(keys, k)
+			}
+			groups[k] = append(groups[k], c)
+		}
+	}
+	values := make(map[string]interface{})
+	for _, k := range keys {
+		v, err := d.combine(groups[k])
+		if err != nil {
+			return nil, err
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+func (d *Document) valueOf(e entry) (interface{}, error) {
+	switch n := e.node.(type) {
+	case *object:
+		return d.group([]entry{e})
+	case *option:
+		if n.Value == nil {
+			return nil, nil
+		}
+		return eval(n.Value, docEval{scope: e.parent, env: d.env})
+	default:
+		return nil, fmt.Errorf("%T can not be evaluated", n)
+	}
+}
+
+func (d *Document) Set(path string, value interface{}) error {
+	segs, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	res, err := find(d.root, segs)
+	if err != nil {
+		return err
+	}
+	if len(res.missing) > 0 {
+		return d.insert(res, value)
+	}
+	if len(res.found) > 1 {
+		return fmt.Errorf("%s: %w", path, ErrAmbiguous)
+	}
+	var (
+		found = res.found[0]
+		last  = segs[len(segs)-1]
+	)
+	if found.isLabel() {
+		return fmt.Errorf("%s: labeled block can not be replaced", path)
+	}
+	n, err := encodeNode(last.name, value)
+	if err != nil {
+		return err
+	}
+	opt, ok := found.node.(*option)
+	if ok && res.element >= 0 {
+		return d.setElement(opt, res.element, n)
+	}
+	if other, ok := n.(*option); ok && opt != nil {
+		return d.replaceValue(opt, other.Value)
+	}
+	pos, end := found.node.Pos(), found.node.End()
+	var (
+		offset = lineStart(d.source, pos.Offset)
+		base   = d.indentOf(pos.Offset)
+	)
+	str, err := renderNodes(base, n)
+	if err != nil {
+		return err
+	}
+	return d.splice(offset, lineEnd(d.source, end.Offset), str)
+}
+
+func (d *Document) Delete(path string) error {
+	segs, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	res, err := find(d.root, segs)
+	if err != nil {
+		return err
+	}
+	if len(res.missing) > 0 {
+		return fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	if len(res.found) > 1 {
+		return fmt.Errorf("%s: %w", path, ErrAmbiguous)
+	}
+	found := res.found[0]
+	if opt, ok := found.node.(*option); ok && res.element >= 0 {
+		return d.setElement(opt, res.element, nil)
+	}
+	pos, end := nodeSpan(found.node)
+	return d.splice(lineStart(d.source, pos.Offset), lineEnd(d.source, end.Offset), "")
+}
+
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(d.source)
+	return int64(n), err
+}
+
+func (d *Document) Bytes() []byte {
+	return append([]byte(nil), d.source...)
+}
+
+func (d *Document) insert(res *pathResult, value interface{}) error {
+	if len(res.parents) > 1 {
+		return fmt.Errorf("%s: %w", res.missing[0].name, ErrAmbiguous)
+	}
+	parent := res.parents[0]
+	if parent.isLabel() {
+		return fmt.Errorf("%s: node can not be inserted in labeled block", res.missing[0].name)
+	}
+	obj, ok := parent.node.(*object)
+	if !ok {
+		return notAnObject(res.missing[0].name)
+	}
+	last := res.missing[len(res.missing)-1]
+	n, err := encodeNode(last.name, value)
+	if err != nil {
+		return err
+	}
+	for i := len(res.missing) - 2; i >= 0; i-- {
+		nest := createObject(res.missing[i].name)
+		nest.append(n)
+		n = nest
+	}
+	var (
+		sibling Node
+		base    string
+	)
+	for _, c := range obj.Nodes {
+		if base == "" {
+			if pos := c.Pos(); pos.Line > 0 {
+				base = d.indentOf(pos.Offset)
+			}
+		}
+		if nodeName(c) == res.missing[0].name {
+			sibling = c
+		}
+	}
+	if len(obj.Nodes) == 0 && obj != d.root {
+		base = d.indentOf(obj.pos.Offset) + indent
+	}
+	str, err := renderNodes(base, n)
+	if err != nil {
+		return err
+	}
+	if sibling != nil {
+		_, end := nodeSpan(sibling)
+		offset := lineEnd(d.source, end.Offset)
+		return d.splice(offset, offset, str)
+	}
+	if obj == d.root && obj.end.Line == 0 {
+		offset := len(d.source)
+		if offset > 0 && d.source[offset-1] != nl {
+			str = "\n" + str
+		}
+		return d.splice(offset, offset, str)
+	}
+	offset := lineStart(d.source, obj.end.Offset)
+	if strings.TrimSpace(string(d.source[offset:obj.end.Offset])) != "" {
+		offset = obj.end.Offset
+		str = "\n" + str + d.indentOf(obj.pos.Offset)
+	}
+	return d.splice(offset, offset, str)
+}
+
+func (d *Document) setElement(opt *option, at int, n Node) error {
+	arr, ok := opt.Value.(*array)
+	if !ok {
+		return fmt.Errorf("%s is not an array", opt.Ident)
+	}
+	if n != nil {
+		other, ok := n.(*option)
+		if !ok {
+			return fmt.Errorf("%s: object can not be inserted in array", opt.Ident)
+		}
+		n = other.Value
+	}
+	beg, end, ok := d.elementSpan(arr.Nodes[at])
+	if !ok {
+		cp := createArray()
+		for i := range arr.Nodes {
+			if i != at {
+				cp.Append(arr.Nodes[i])
+			} else if n != nil {
+				cp.Append(n)
+			}
+		}
+		return d.replaceValue(opt, cp)
+	}
+	if n != nil {
+		p := printer{
+			base: d.indentOf(beg),
+		}
+		str, err := p.formatValue(n, beg-lineStart(d.source, beg)-len(p.base))
+		if err != nil {
+			return err
+		}
+		return d.splice(beg, end, str)
+	}
+	after := end
+	for after < len(d.source) && isBlank(rune(d.source[after])) {
+		after++
+	}
+	comma := after < len(d.source) && d.source[after] == ','
+	if comma {
+		after++
+	}
+	var (
+		first = lineStart(d.source, beg)
+		last  = lineEnd(d.source, after)
+		rest  = strings.TrimSpace(string(d.source[after:last]))
+	)
+	if strings.TrimSpace(string(d.source[first:beg])) == "" && (rest == "" || isCommentText(rest)) {
+		if c := getComment(arr.Nodes[at]); c != nil && c.Pos().Line > 0 && c.Pos().Line < arr.Nodes[at].Pos().Line {
+			first = lineStart(d.source, c.Pos().Offset)
+		}
+		return d.splice(first, last, "")
+	}
+	if comma {
+		for after < len(d.source) && isBlank(rune(d.source[after])) {
+			after++
+		}
+		return d.splice(beg, after, "")
+	}
+	if at > 0 {
+		if _, prev, ok := d.elementSpan(arr.Nodes[at-1]); ok {
+			beg = prev
+		}
+	}
+	return d.splice(beg, end, "")
+}
+
+func (d *Document) elementSpan(n Node) (int, int, bool) {
+	beg := n.Pos().Offset
+	if beg <= 0 || beg >= len(d.source) {
+		return 0, 0, false
+	}
+	p, err := NewParser(bytes.NewReader(d.source[beg:]))
+	if err != nil {
+		return 0, 0, false
+	}
+	p.raw = true
+	if _, err := p.parseExpr(powLowest); err != nil {
+		return 0, 0, false
+	}
+	switch p.curr.Type {
+	case Comma, EOL, EndArr, Comment:
+	default:
+		return 0, 0, false
+	}
+	end := beg + p.curr.Offset
+	for end > beg && isSpace(rune(d.source[end-1])) {
+		end--
+	}
+	return beg, end, true
+}
+
+func (d *Document) replaceValue(opt *option, n Node) error {
+	var (
+		beg  = opt.eq.Offset + 1
+		end  = opt.end.Offset
+		line = lineStart(d.source, opt.pos.Offset)
+		base = d.indentOf(opt.pos.Offset)
+	)
+	for beg < end && isBlank(rune(d.source[beg])) {
+		beg++
+	}
+	for end > beg && isSpace(rune(d.source[end-1])) {
+		end--
+	}
+	p := printer{
+		base: base,
+	}
+	str, err := p.formatValue(n, beg-line-len(base))
+	if err != nil {
+		return err
+	}
+	if beg == opt.eq.Offset+1 {
+		str = " " + str
+	}
+	return d.splice(beg, end, str)
+}
+
+func (d *Document) splice(beg, end int, str string) error {
+	var buf bytes.Buffer
+	buf.Write(d.source[:beg])
+	buf.WriteString(str)
+	buf.Write(d.source[end:])
+	return d.reset(buf.Bytes())
+}
+
+func (d *Document) reset(buf []byte) error {
+	p, err := NewParser(bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	p.raw = true
+	n, err := p.Parse()
+	if err != nil {
+		return err
+	}
+	obj, ok := n.(*object)
+	if !ok {
+		return fmt.Errorf("root node is not an object")
+	}
+	d.root = obj
+	d.tree = nil
+	d.source = p.scan.input
+	return nil
+}
+
+func (d *Document) evaluate() (*object, error) {
+	if d.tree != nil {
+		return d.tree, nil
+	}
+	p, err := NewParser(bytes.NewReader(d.source))
+	if err != nil {
+		return nil, err
+	}
+	p.file = d.file
+	p.env = d.env
+	p.policy = d.policy
+	n, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := n.(*object)
+	if !ok {
+		return nil, fmt.Errorf("root node is not an object")
+	}
+	d.tree = obj
+	return obj, nil
+}
+
+func (d *Document) indentOf(offset int) string {
+	var (
+		beg = lineStart(d.source, offset)
+		end = beg
+	)
+	for end < len(d.source) && isBlank(rune(d.source[end])) {
+		end++
+	}
+	return string(d.source[beg:end])
+}
+
+type pathResult struct {
+	found   []entry
+	parents []entry
+	missing []segment
+	element int
+}
+
+func find(root *object, segs []segment) (*pathResult, error) {
+	var (
+		res  = pathResult{element: -1}
+		curr = []entry{{node: root}}
+	)
+	for i, s := range segs {
+		var next []entry
+		for _, e := range curr {
+			for _, c := range e.children() {
+				if c.name() == s.name {
+					next = append(next, c)
+				}
+			}
+		}
+		if s.indexed {
+			at := s.index
+			if i == len(segs)-1 && len(next) == 1 && isArrayOption(next[0].node) {
+				arr := next[0].node.(*option).Value.(*array)
+				if at < 0 {
+					at += len(arr.Nodes)
+				}
+				if at < 0 || at >= len(arr.Nodes) {
+					return nil, fmt.Errorf("%s: index out of range (%d)", s.name, s.index)
+				}
+				res.found, res.element = next, at
+				return &res, nil
+			}
+			if at < 0 {
+				at += len(next)
+			}
+			if at == len(next) {
+				next = nil
+			} else if at < 0 || at > len(next) {
+				return nil, fmt.Errorf("%s: index out of range (%d)", s.name, s.index)
+			} else {
+				next = next[at : at+1]
+			}
+		}
+		if len(next) == 0 {
+			res.parents = curr
+			res.missing = segs[i:]
+			return &res, nil
+		}
+		curr = next
+	}
+	res.found = curr
+	return &res, nil
+}
+
+type entry struct {
+	node   Node
+	parent *object
+	depth  int
+}
+
+func (e entry) name() string {
+	switch n := e.node.(type) {
+	case *option:
+		return n.Ident
+	case *object:
+		if e.depth == 0 {
+			return n.Name
+		}
+		return n.Labels[e.depth-1]
+	default:
+		return ""
+	}
+}
+
+func (e entry) isLabel() bool {
+	obj, ok := e.node.(*object)
+	return ok && e.depth < len(obj.Labels)
+}
+
+func (e entry) children() []entry {
+	obj, ok := e.node.(*object)
+	if !ok {
+		return nil
+	}
+	if e.isLabel() {
+		return []entry{{node: obj, parent: e.parent, depth: e.depth + 1}}
+	}
+	var list []entry
+	for _, n := range obj.Nodes {
+		if arr, ok := n.(*array); ok {
+			for _, n := range arr.Nodes {
+				list = append(list, entry{node: n, parent: obj})
+			}
+			continue
+		}
+		list = append(list, entry{node: n, parent: obj})
+	}
+	return list
+}
+
+type segment struct {
+	name    string
+	index   int
+	indexed bool
+}
+
+func parsePath(path string) ([]segment, error) {
+	var segs []segment
+	for _, str := range strings.Split(path, ".") {
+		var s segment
+		if x := strings.IndexByte(str, lsquare); x >= 0 {
+			if !strings.HasSuffix(str, "]") {
+				return nil, fmt.Errorf("%s: invalid path", path)
+			}
+			i, err := strconv.Atoi(str[x+1 : len(str)-1])
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid index in path", path)
+			}
+			s.index, s.indexed = i, true
+			str = str[:x]
+		}
+		if str == "" {
+			return nil, fmt.Errorf("%s: invalid path", path)
+		}
+		s.name = str
+		segs = append(segs, s)
+	}
+	return segs, nil
+}
+
+func encodeNode(ident string, value interface{}) (Node, error) {
+	var (
+		e   Encoder
+		obj = createObject("")
+	)
+	if err := e.encodeField(obj, ident, reflect.ValueOf(value)); err != nil {
+		return nil, err
+	}
+	if len(obj.Nodes) != 1 {
+		return nil, fmt.Errorf("%s: value can not be encoded", ident)
+	}
+	return obj.Nodes[0], nil
+}
+
+func renderNodes(base string, nodes ...Node) (string, error) {
+	var (
+		buf bytes.Buffer
+		obj = createObject("")
+	)
+	obj.Nodes = append(obj.Nodes, nodes...)
+	p := printer{
+		writer: bufio.NewWriter(&buf),
+		base:   base,
+	}
+	if err := p.printBody(obj); err != nil {
+		return "", err
+	}
+	if err := p.writer.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func nodeName(n Node) string {
+	switch n := n.(type) {
+	case *option:
+		return n.Ident
+	case *object:
+		return n.Name
+	default:
+		return ""
+	}
+}
+
+func isArrayOption(n Node) bool {
+	opt, ok := n.(*option)
+	if !ok {
+		return false
+	}
+	_, ok = opt.Value.(*array)
+	return ok
+}
+
+func lineStart(buf []byte, offset int) int {
+	if offset > len(buf) {
+		offset = len(buf)
+	}
+	return bytes.LastIndexByte(buf[:offset], nl) + 1
+}
+
+func lineEnd(buf []byte, offset int) int {
+	if offset >= len(buf) {
+		return len(buf)
+	}
+	x := bytes.IndexByte(buf[offset:], nl)
+	if x < 0 {
+		return len(buf)
+	}
+	return offset + x + 1
+}
+
+func isCommentText(str string) bool {
+	return strings.HasPrefix(str, "#") || strings.HasPrefix(str, "/*")
+}
+
+func isSpace(b rune) bool {
+	return isBlank(b) || isNL(b)
+}
+
+type docEval struct {
+	scope *object
+	env   *Env
+	depth int
+}
+
+func (e docEval) resolveVariable(v *variable) (interface{}, error) {
+	if !v.IsLocal() {
+		return e.env.resolve(v.Name())
+	}
+	if e.depth >= maxDepth {
+		return nil, fmt.Errorf("%s: too many nested references", v.Name())
+	}
+	for obj := e.scope; obj != nil; obj = obj.parent {
+		for i := len(obj.Nodes) - 1; i >= 0; i-- {
+			opt, ok := obj.Nodes[i].(*option)
+			if !ok || opt.Ident != v.Name() || opt.Value == nil {
+				continue
+			}
+			nest := e
+			nest.scope = obj
+			nest.depth++
+			return eval(opt.Value, nest)
+		}
+	}
+	return nil, fmt.Errorf("%s: undefined option", v.Name())
+}
+
+func (e docEval) callFunction(c *call, ev evaluator) (interface{}, error) {
+	for obj := e.scope; obj != nil; obj = obj.parent {
+		for _, n := range obj.Nodes {
+			if fn, ok := n.(*function); ok && fn.Ident == c.Ident {
+				return fn.call(c, ev)
+			}
+		}
+	}
+	fn, ok := e.env.function(c.Ident)
+	if !ok {
+		return nil, fmt.Errorf("%s: undefined function", c.Ident)
+	}
+	return callNative(fn, c, ev)
+}
@@ -0,0 +1,186 @@
+package fig_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/midbel/fig"
+)
+
+func ExampleDocument() {
+	const demo = `# project metadata
+version = "1.0.1" # bump me
+
+server {
+  addr   = "192.168.67.181"
+  backup = ["10.100.0.1", "10.100.0.2"]
+}
+server {
+  addr = "192.168.67.236"
+  # debug mode
+  debug = true
+}
+
+.include("extra.fig", fatal=false)
+`
+	doc, err := fig.ParseDocument(strings.NewReader(demo))
+	if err != nil {
+		fmt.Printf("fail to parse document: %s\n", err)
+		return
+	}
+	edits := []func() error{
+		func() error { return doc.Set("version", "1.0.2") },
+		func() error { return doc.Set("server[1].addr", "10.0.0.1") },
+		func() error { return doc.Set("server[0].backup[-1]", "10.0.0.2") },
+		func() error { return doc.Delete("server[1].debug") },
+		func() error { return doc.Set("server[2].addr", "10.0.0.3") },
+	}
+	for _, fn := range edits {
+		if err := fn(); err != nil {
+			fmt.Printf("fail to edit document: %s\n", err)
+			return
+		}
+	}
+	if err := doc.Set("server.addr", "127.0.0.1"); err != nil {
+		fmt.Println(err)
+	}
+	doc.WriteTo(os.Stdout)
+	// Output:
+	// server.addr: path is ambiguous
+	// # project metadata
+	// version = "1.0.2" # bump me
+	//
+	// server {
+	//   addr   = "192.168.67.181"
+	//   backup = ["10.100.0.1", "10.0.0.2"]
+	// }
+	// server {
+	//   addr = "10.0.0.1"
+	// }
+	// server {
+	//   addr = "10.0.0.3"
+	// }
+	//
+	// .include("extra.fig", fatal=false)
+}
+
+func ExampleDocument_Get() {
+	const demo = `
+name    = "demo"
+timeout = 30s
+server {
+  addr   = "192.168.67.181"
+  backup = ["10.100.0.1", "10.100.0.2"]
+}
+server {
+  addr = "192.168.67.236"
+  port = 80
+}
+ports tcp {
+  list = [80, 443]
+}
+`
+	doc, err := fig.ParseDocument(strings.NewReader(demo))
+	if err != nil {
+		fmt.Printf("fail to parse document: %s\n", err)
+		return
+	}
+	addr, _ := doc.GetString("server[1].addr")
+	backup, _ := doc.GetString("server[0].backup[-1]")
+	port, _ := doc.GetInt("server[-1].port")
+	timeout, _ := doc.GetDuration("timeout")
+	list, _ := doc.GetStringSlice("server.addr")
+	tcp, _ := doc.Get("ports.tcp.list")
+
+	fmt.Println(addr, backup, port, timeout)
+	fmt.Println(list)
+	fmt.Println(tcp)
+	if _, ok := doc.Lookup("server[2]"); !ok {
+		fmt.Println("server[2] not found")
+	}
+	// Output:
+	// 192.168.67.236 10.100.0.2 80 30s
+	// [192.168.67.181 192.168.67.236]
+	// [80 443]
+	// server[2] not found
+}
+
+func ExampleDocument_Get_macros() {
+	const demo = `
+.define(base) {
+  ttl = 60
+}
+obj {
+  .apply(name=base)
+}
+`
+	doc, err := fig.ParseDocument(strings.NewReader(demo))
+	if err != nil {
+		fmt.Printf("fail to parse document: %s\n", err)
+		return
+	}
+	ttl, err := doc.GetInt("obj.ttl")
+	fmt.Println(ttl, err)
+
+	doc.Set("obj.port", 80)
+	port, err := doc.GetInt("obj.port")
+	fmt.Println(port, err)
+
+	r, err := os.Open("testdata/nested/main.fig")
+	if err != nil {
+		fmt.Printf("fail to open document: %s\n", err)
+		return
+	}
+	defer r.Close()
+	if doc, err = fig.ParseDocument(r); err != nil {
+		fmt.Printf("fail to parse document: %s\n", err)
+		return
+	}
+	fmt.Println(doc.GetString("name"))
+	fmt.Println(doc.GetString("data"))
+	// Output:
+	// 60 <nil>
+	// 80 <nil>
+	// child <nil>
+	// hello <nil>
+}
+
+func ExampleDocument_Set_array() {
+	const demo = `hosts = [
+  # primary
+  "10.0.0.1", # main
+
+  "10.0.0.2",
+  # backup
+  "10.0.0.3", # last
+]
+ports = [80, 443, 8080] # web
+`
+	doc, err := fig.ParseDocument(strings.NewReader(demo))
+	if err != nil {
+		fmt.Printf("fail to parse document: %s\n", err)
+		return
+	}
+	edits := []func() error{
+		func() error { return doc.Set("hosts[0]", "192.168.0.1") },
+		func() error { return doc.Delete("hosts[-1]") },
+		func() error { return doc.Delete("ports[1]") },
+		func() error { return doc.Set("ports[-1]", 8443) },
+	}
+	for _, fn := range edits {
+		if err := fn(); err != nil {
+			fmt.Printf("fail to edit document: %s\n", err)
+			return
+		}
+	}
+	doc.WriteTo(os.Stdout)
+	// Output:
+	// hosts = [
+	//   # primary
+	//   "192.168.0.1", # main
+	//
+	//   "10.0.0.2",
+	// ]
+	// ports = [80, 8443] # web
+}
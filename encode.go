@@ -0,0 +1,275 @@
+package fig
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Encoder struct {
+	writer io.Writer
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		writer: w,
+	}
+}
+
+func (e *Encoder) Encode(v interface{}) error {
+	value, ok := indirectValue(reflect.ValueOf(v))
+	if !ok {
+		return fmt.Errorf("expecting not nil value")
+	}
+	var (
+		obj = createObject("root")
+		err error
+	)
+	switch k := value.Kind(); k {
+	case reflect.Struct:
+		err = e.encodeStruct(obj, value)
+	case reflect.Map:
+		err = e.encodeMap(obj, value)
+	default:
+		err = fmt.Errorf("struct/map type expected! got %s", k)
+	}
+	if err != nil {
+		return err
+	}
+	return writeNode(e.writer, obj)
+}
+
+func (e *Encoder) encodeStruct(obj *object, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		ft := t.Field(i)
+		if ft.PkgPath != "" {
+			continue
+		}
+		tag, ok := parseTag(ft)
+		if !ok {
+			continue
+		}
+		if tag.OmitEmpty && isZero(v.Field(i)) {
+			continue
+		}
+		name := tag.Name
+		if !tag.Renamed {
+			name = strings.ToLower(name)
+		}
+		if err := e.encodeField(obj, name, v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encodeMap(obj *object, v reflect.Value) error {
+	if k := v.Type().Key().Kind(); k != reflect.String {
+		return fmt.Errorf("key should be of type string")
+	}
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, k := range keys {
+		if err := e.encodeField(obj, k.String(), v.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encodeField(obj *object, ident string, v reflect.Value) error {
+	v, ok := indirectValue(v)
+	if !ok {
+		return nil
+	}
+	if n, ok, err := e.encodeSpecial(v); ok {
+		if err != nil {
+			return err
+		}
+		return obj.set(createOption(ident, n))
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map:
+		nest, err := e.encodeObject(ident, v)
+		if err != nil {
+			return err
+		}
+		return obj.set(nest)
+	case reflect.Slice, reflect.Array:
+		if !isObjectArray(v) {
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			vf, _ := indirectValue(v.Index(i))
+			nest, err := e.encodeObject(ident, vf)
+			if err != nil {
+				return err
+			}
+			if err := obj.set(nest); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+	}
+	n, err := e.encodeValue(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ident, err)
+	}
+	return obj.set(createOption(ident, n))
+}
+
+func (e *Encoder) encodeObject(ident string, v reflect.Value) (*object, error) {
+	var (
+		obj = createObject(ident)
+		err error
+	)
+	if v.Kind() == reflect.Map {
+		err = e.encodeMap(obj, v)
+	} else {
+		err = e.encodeStruct(obj, v)
+	}
+	return obj, err
+}
+
+func (e *Encoder) encodeValue(v reflect.Value) (Node, error) {
+	v, ok := indirectValue(v)
+	if !ok {
+		return nil, fmt.Errorf("nil value can not be encoded")
+	}
+	if n, ok, err := e.encodeSpecial(v); ok {
+		return n, err
+	}
+	switch k := v.Kind(); k {
+	case reflect.Slice, reflect.Array:
+		if k == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return createLiteralFromString(string(v.Bytes())), nil
+		}
+		return e.encodeArray(v)
+	case reflect.Struct, reflect.Map:
+		return nil, fmt.Errorf("object can not be encoded inside an array")
+	default:
+		return e.encodeLiteral(v)
+	}
+}
+
+func (e *Encoder) encodeArray(v reflect.Value) (Node, error) {
+	arr := createArray()
+	for i := 0; i < v.Len(); i++ {
+		n, err := e.encodeValue(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		arr.Append(n)
+	}
+	return arr, nil
+}
+
+func (e *Encoder) encodeLiteral(v reflect.Value) (Node, error) {
+	var tok Token
+	switch k := v.Kind(); k {
+	case reflect.String:
+		tok = makeToken(v.String(), String)
+	case reflect.Bool:
+		tok = makeToken(strconv.FormatBool(v.Bool()), Boolean)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		tok = makeToken(strconv.FormatInt(v.Int(), 10), Integer)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		tok = makeToken(strconv.FormatUint(v.Uint(), 10), Integer)
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%f can not be encoded", f)
+		}
+		str := strconv.FormatFloat(f, 'f', -1, v.Type().Bits())
+		if !strings.ContainsAny(str, ".eE") {
+			str += ".0"
+		}
+		tok = makeToken(str, Float)
+	default:
+		return nil, fmt.Errorf("primitive type expected! got %s", k)
+	}
+	return createLiteral(tok), nil
+}
+
+func (e *Encoder) encodeSpecial(v reflect.Value) (Node, bool, error) {
+	var str string
+	switch t := v.Type(); {
+	case t == timetype:
+		str = v.Interface().(time.Time).UTC().Format(timeformat[0])
+	case t == urltype:
+		u := v.Interface().(url.URL)
+		str = u.String()
+	case t == regextype:
+		rx := reflect.New(t)
+		rx.Elem().Set(v)
+		str = rx.Interface().(*regexp.Regexp).String()
+	case t == iptype:
+		str = v.Interface().(net.IP).String()
+	case t == durationtype:
+		str = v.Interface().(time.Duration).String()
+	case t == addrtype || t == addrporttype:
+		str = v.Interface().(fmt.Stringer).String()
+	default:
+		return nil, false, nil
+	}
+	return createLiteralFromString(str), true, nil
+}
+
+func isObjectArray(v reflect.Value) bool {
+	if v.Len() == 0 {
+		return false
+	}
+	for i := 0; i < v.Len(); i++ {
+		vf, ok := indirectValue(v.Index(i))
+		if !ok {
+			return false
+		}
+		if k := vf.Kind(); k != reflect.Struct && k != reflect.Map {
+			return false
+		}
+		if isSpecial(vf.Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+func isSpecial(t reflect.Type) bool {
+	switch t {
+	case timetype, durationtype, urltype, regextype, iptype, addrtype, addrporttype:
+		return true
+	default:
+		return false
+	}
+}
+
+func indirectValue(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.IsValid()
+}
@@ -0,0 +1,128 @@
+package fig_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/midbel/fig"
+)
+
+func ExampleEncoder_Encode() {
+	type Server struct {
+		Addr string
+		Host string   `fig:"hostname"`
+		Back []string `fig:"backup"`
+	}
+	type Config struct {
+		Email   string `fig:"contact"`
+		Admin   bool
+		TTL     int
+		Ratio   float64
+		When    time.Time
+		Meta    map[string]interface{} `fig:"metadata"`
+		Servers []Server               `fig:"server"`
+		Skip    string                 `fig:"-"`
+	}
+	in := Config{
+		Email: "midbel@midbel.org",
+		Admin: true,
+		TTL:   100,
+		Ratio: 1,
+		When:  time.Date(2022, 1, 28, 0, 0, 0, 0, time.UTC),
+		Meta: map[string]interface{}{
+			"version": "1.0.1",
+			"vcs":     "git",
+		},
+		Servers: []Server{
+			{Addr: "192.168.67.181", Host: "alpha", Back: []string{"10.100.0.1", "10.100.0.2"}},
+			{Addr: "192.168.67.236", Host: "omega"},
+		},
+		Skip: "skipped",
+	}
+	if err := fig.NewEncoder(os.Stdout).Encode(in); err != nil {
+		fmt.Printf("fail to encode config: %s\n", err)
+		return
+	}
+	// Output:
+	// contact = "midbel@midbel.org"
+	// admin   = true
+	// ttl     = 100
+	// ratio   = 1.0
+	// when    = "2022-01-28T00:00:00Z"
+	// metadata {
+	//   vcs     = "git"
+	//   version = "1.0.1"
+	// }
+	// server {
+	//   addr     = "192.168.67.181"
+	//   hostname = "alpha"
+	//   backup   = ["10.100.0.1", "10.100.0.2"]
+	// }
+	// server {
+	//   addr     = "192.168.67.236"
+	//   hostname = "omega"
+	//   backup   = []
+	// }
+}
+
+func ExampleEncoder_Encode_roundtrip() {
+	type Port struct {
+		List   []uint16
+		Action string
+	}
+	type Config struct {
+		Name  string
+		Ports []Port
+		Quote string
+		Text  string
+		Lines string
+		Ratio float32
+	}
+	var (
+		in = Config{
+			Name:  "demo",
+			Ports: []Port{{List: []uint16{80, 443}, Action: "allow"}},
+			Quote: `say "hello"`,
+			Text:  "  indented\nline  ",
+			Lines: "it's a \"first\"\nand second line",
+			Ratio: 0.1,
+		}
+		out Config
+		buf strings.Builder
+	)
+	if err := fig.NewEncoder(&buf).Encode(in); err != nil {
+		fmt.Printf("fail to encode config: %s\n", err)
+		return
+	}
+	if err := fig.NewDecoder(strings.NewReader(buf.String())).Decode(&out); err != nil {
+		fmt.Printf("fail to decode config: %s\n", err)
+		return
+	}
+	fmt.Printf("%+v\n", out.Ports)
+	fmt.Printf("%q %q %q %q\n", out.Name, out.Quote, out.Text, out.Lines)
+
+	buf.Reset()
+	in.Text = "  it's \"quoted\"  "
+	if err := fig.NewEncoder(&buf).Encode(in); err != nil {
+		fmt.Printf("fail to encode config: %s\n", err)
+		return
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "text") || strings.HasPrefix(line, "ratio") {
+			fmt.Println(line)
+		}
+	}
+	if err := fig.NewDecoder(strings.NewReader(buf.String())).Decode(&out); err != nil {
+		fmt.Printf("fail to decode config: %s\n", err)
+		return
+	}
+	fmt.Printf("%q %v\n", out.Text, out.Ratio)
+	// Output:
+	// [{List:[80 443] Action:allow}]
+	// "demo" "say \"hello\"" "  indented\nline  " "it's a \"first\"\nand second line"
+	// text  = "  it's " + '"' + "quoted" + '"' + "  "
+	// ratio = 0.1
+	// "  it's \"quoted\"  " 0.1
+}
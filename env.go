@@ -11,6 +11,7 @@ type Resolver interface {
 type Env struct {
 	parent *Env
 	values map[string]interface{}
+	funcs  FuncMap
 }
 
 func EmptyEnv() *Env {
@@ -47,6 +48,17 @@ func (e *Env) define(ident string, value interface{}) {
 	e.values[ident] = value
 }
 
+func (e *Env) function(ident string) (interface{}, bool) {
+	for ; e != nil; e = e.parent {
+		if e.funcs != nil {
+			fn, ok := e.funcs[ident]
+			return fn, ok
+		}
+	}
+	fn, ok := builtins[ident]
+	return fn, ok
+}
+
 func (e *Env) unwrap() *Env {
 	return e.parent
 }
@@ -0,0 +1,113 @@
+package fig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type ParseError struct {
+	File    string
+	Line    int
+	Column  int
+	Token   Token
+	Path    string
+	Snippet string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	return formatError(e.File, e.Line, e.Column, e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+type ErrorList []*ParseError
+
+func (e ErrorList) Error() string {
+	var list []string
+	for _, err := range e {
+		list = append(list, err.Error())
+	}
+	return strings.Join(list, "\n")
+}
+
+type DecodeError struct {
+	File    string
+	Line    int
+	Column  int
+	Path    string
+	Snippet string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return formatError(e.File, e.Line, e.Column, e.Path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func decodeError(path string, pos Position, err error) error {
+	var de *DecodeError
+	if err == nil || errors.As(err, &de) {
+		return err
+	}
+	return &DecodeError{
+		Line:   pos.Line,
+		Column: pos.Col,
+		Path:   path,
+		Err:    err,
+	}
+}
+
+func formatError(file string, line, col int, path string, err error) string {
+	msg := err.Error()
+	if path != "" {
+		msg = fmt.Sprintf("%s: %s", path, msg)
+	}
+	var loc []string
+	if file != "" {
+		loc = append(loc, file)
+	}
+	if line > 0 {
+		loc = append(loc, strconv.Itoa(line), strconv.Itoa(col))
+	}
+	if len(loc) == 0 {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(loc, ":"), msg)
+}
+
+func makeSnippet(src []byte, line, col int) string {
+	if line <= 0 {
+		return ""
+	}
+	lines := bytes.Split(src, []byte{nl})
+	if line > len(lines) {
+		return ""
+	}
+	var (
+		text   = string(lines[line-1])
+		prefix = strconv.Itoa(line)
+		gutter = strings.Repeat(" ", len(prefix))
+		caret  strings.Builder
+	)
+	for i, r := range []rune(text) {
+		if i >= col-1 {
+			break
+		}
+		if r == '\t' {
+			caret.WriteRune(r)
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+	return fmt.Sprintf("%s | %s\n%s | %s", prefix, text, gutter, caret.String())
+}
@@ -0,0 +1,137 @@
+package fig_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/midbel/fig"
+)
+
+func ExampleParseError() {
+	const demo = `
+name = "demo"
+server {
+  addr = = "192.168.67.181"
+}
+`
+	_, err := fig.Parse(strings.NewReader(demo))
+
+	var pe *fig.ParseError
+	if !errors.As(err, &pe) {
+		fmt.Println("expected parse error")
+		return
+	}
+	fmt.Println(pe)
+	fmt.Println(pe.Snippet)
+	// Output:
+	// 4:10: server: unexpected token: <assignment>
+	// 4 |   addr = = "192.168.67.181"
+	//   |          ^
+}
+
+func ExampleDecodeError() {
+	const demo = `
+server {
+  port = "http"
+}
+`
+	c := struct {
+		Server struct {
+			Port int
+		}
+	}{}
+	err := fig.NewDecoder(strings.NewReader(demo)).Decode(&c)
+
+	var de *fig.DecodeError
+	if !errors.As(err, &de) {
+		fmt.Println("expected decode error")
+		return
+	}
+	fmt.Println(de.Path, de.Line, de.Column)
+	fmt.Println(de.Snippet)
+	// Output:
+	// server.port 3 3
+	// 3 |   port = "http"
+	//   |   ^
+}
+
+func ExampleParser_Tolerant() {
+	const demo = `
+name = = "demo"
+server {
+  addr = "192.168.67.181"
+  port = 80 +
+}
+version = "1.0.1"
+`
+	p, err := fig.NewParser(strings.NewReader(demo))
+	if err != nil {
+		fmt.Printf("fail to create parser: %s\n", err)
+		return
+	}
+	p.Tolerant()
+	n, err := p.Parse()
+
+	var list fig.ErrorList
+	if errors.As(err, &list) {
+		for _, e := range list {
+			fmt.Println(e)
+		}
+	}
+	fig.Inspect(n, func(n fig.Node) bool {
+		if opt, ok := n.(fig.OptionNode); ok {
+			fmt.Println(opt.Key())
+		}
+		return true
+	})
+	// Output:
+	// 2:8: unexpected token: <assignment>
+	// 5:14: server: unexpected token: <eol>
+	// addr
+	// version
+}
+
+func TestParseUnterminatedTemplate(t *testing.T) {
+	for _, tolerant := range []bool{false, true} {
+		p, err := fig.NewParser(strings.NewReader("name = demo\nx = `unterminated ${name}\n"))
+		if err != nil {
+			t.Fatalf("fail to create parser: %s", err)
+		}
+		if tolerant {
+			p.Tolerant()
+		}
+		errc := make(chan error, 1)
+		go func() {
+			_, err := p.Parse()
+			errc <- err
+		}()
+		select {
+		case err = <-errc:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("parser does not stop at the end of an unterminated template")
+		}
+		if err == nil || !strings.Contains(err.Error(), "unterminated template") {
+			t.Errorf("unterminated template error expected! got %v", err)
+		}
+	}
+}
+
+func TestMacroErrorPosition(t *testing.T) {
+	const demo = "name = demo\n  .include(\"missing.fig\", fatal=true)\n\nport = 80\n"
+	_, err := fig.Parse(strings.NewReader(demo))
+
+	var pe *fig.ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("parse error expected! got %v", err)
+	}
+	if pe.Line != 2 || pe.Column != 4 {
+		t.Errorf("error expected at 2:4! got %d:%d", pe.Line, pe.Column)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file error expected! got %v", err)
+	}
+}
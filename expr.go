@@ -0,0 +1,405 @@
+package fig
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var errZero = errors.New("division by zero")
+
+type evaluator interface {
+	resolveVariable(*variable) (interface{}, error)
+	callFunction(*call, evaluator) (interface{}, error)
+}
+
+type frame struct {
+	evaluator
+	values map[string]interface{}
+	depth  int
+}
+
+func (f frame) resolveVariable(v *variable) (interface{}, error) {
+	if val, ok := f.values[v.Name()]; ok && v.IsLocal() {
+		return val, nil
+	}
+	return f.evaluator.resolveVariable(v)
+}
+
+func (f *function) call(c *call, ev evaluator) (interface{}, error) {
+	fr := frame{
+		evaluator: ev,
+		values:    make(map[string]interface{}),
+	}
+	if curr, ok := ev.(frame); ok {
+		fr.evaluator = curr.evaluator
+		fr.depth = curr.depth + 1
+	}
+	if fr.depth >= maxDepth {
+		return nil, fmt.Errorf("%s: maximum call depth reached", f.Ident)
+	}
+	if len(c.Args) > len(f.Params) {
+		return nil, fmt.Errorf("%s: too many arguments given", f.Ident)
+	}
+	for i, n := range c.Args {
+		v, err := eval(n, ev)
+		if err != nil {
+			return nil, err
+		}
+		fr.values[f.Params[i].Name] = v
+	}
+	for _, k := range c.Keys() {
+		if !f.has(k) {
+			return nil, fmt.Errorf("%s: unknown parameter %s", f.Ident, k)
+		}
+		if _, ok := fr.values[k]; ok {
+			return nil, fmt.Errorf("%s: parameter %s given as positional and keyword", f.Ident, k)
+		}
+		v, err := eval(c.Kwargs[k], ev)
+		if err != nil {
+			return nil, err
+		}
+		fr.values[k] = v
+	}
+	for _, p := range f.Params {
+		if _, ok := fr.values[p.Name]; ok {
+			continue
+		}
+		if p.Default == nil {
+			return nil, fmt.Errorf("%s: parameter %s not supplied", f.Ident, p.Name)
+		}
+		v, err := eval(p.Default, fr)
+		if err != nil {
+			return nil, err
+		}
+		fr.values[p.Name] = v
+	}
+	return eval(f.Body, fr)
+}
+
+func isExpr(n Node) bool {
+	return n != nil && n.Type() == TypeExpr
+}
+
+func eval(n Node, ev evaluator) (interface{}, error) {
+	switch n := n.(type) {
+	case *literal:
+		return n.Get()
+	case *variable:
+		return ev.resolveVariable(n)
+	case *call:
+		if fn, ok := n.scope.getFunction(n.Ident); ok {
+			return fn.call(n, ev)
+		}
+		return ev.callFunction(n, ev)
+	case *template:
+		return evalTemplate(n, ev)
+	case *array:
+		var list []interface{}
+		for _, n := range n.Nodes {
+			v, err := eval(n, ev)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case *slice:
+		v, err := eval(n.Node, ev)
+		if err != nil {
+			return nil, err
+		}
+		return evalSlice(n, v)
+	case *unary:
+		return evalUnary(n, ev)
+	case *binary:
+		return evalBinary(n, ev)
+	case *ternary:
+		v, err := eval(n.Cond, ev)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := toBool(v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return eval(n.Csq, ev)
+		}
+		return eval(n.Alt, ev)
+	default:
+		return nil, fmt.Errorf("%T can not be evaluated", n)
+	}
+}
+
+func evalTemplate(tpl *template, ev evaluator) (string, error) {
+	var str strings.Builder
+	for _, n := range tpl.Nodes {
+		switch n := n.(type) {
+		case *literal:
+			s, _ := n.GetString()
+			str.WriteString(s)
+		case *variable:
+			val, err := ev.resolveVariable(n)
+			if err != nil {
+				return "", err
+			}
+			str.WriteString(toString(val))
+		default:
+			return "", fmt.Errorf("unexpected node type")
+		}
+	}
+	return str.String(), nil
+}
+
+func evalSlice(slc *slice, v interface{}) (interface{}, error) {
+	arr := reflect.ValueOf(v)
+	if !isArray(arr) {
+		return nil, fmt.Errorf("%T can not be sliced", v)
+	}
+	reindex := func(i, size int) int {
+		if i < 0 {
+			i += size
+		}
+		return i
+	}
+	if slc.IsIndex() {
+		i := reindex(slc.From(), arr.Len())
+		if i < 0 || i >= arr.Len() {
+			return nil, fmt.Errorf("index out of range (%d >= %d)", slc.from.index, arr.Len())
+		}
+		return arr.Index(i).Interface(), nil
+	}
+	var (
+		from = reindex(slc.From(), arr.Len())
+		to   = arr.Len()
+	)
+	if slc.to.set {
+		to = reindex(slc.To(), arr.Len())
+	}
+	if from < 0 || to > arr.Len() || from > to {
+		return nil, fmt.Errorf("invalid slice index (%d:%d)", from, to)
+	}
+	return arr.Slice(from, to).Interface(), nil
+}
+
+func evalUnary(u *unary, ev evaluator) (interface{}, error) {
+	v, err := eval(u.Right, ev)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Op.Type {
+	case Not:
+		b, err := toBool(v)
+		return !b, err
+	case Sub:
+		if i, ok := toInt(v); ok {
+			return -i, nil
+		}
+		if f, ok := toFloat(v); ok {
+			return -f, nil
+		}
+		return nil, fmt.Errorf("%s: number expected! got %T", u.Op.Literal, v)
+	default:
+		return nil, fmt.Errorf("%s: unsupported unary operator", u.Op.Literal)
+	}
+}
+
+func evalBinary(b *binary, ev evaluator) (interface{}, error) {
+	left, err := eval(b.Left, ev)
+	if err != nil {
+		return nil, err
+	}
+	if b.Op.Type == And || b.Op.Type == Or {
+		ok, err := toBool(left)
+		if err != nil || ok == (b.Op.Type == Or) {
+			return ok, err
+		}
+		right, err := eval(b.Right, ev)
+		if err != nil {
+			return nil, err
+		}
+		return toBool(right)
+	}
+	right, err := eval(b.Right, ev)
+	if err != nil {
+		return nil, err
+	}
+	switch b.Op.Type {
+	case Eq:
+		return isEqual(left, right), nil
+	case Ne:
+		return !isEqual(left, right), nil
+	case Lt, Le, Gt, Ge:
+		return compareValues(b.Op, left, right)
+	default:
+		return computeValues(b.Op, left, right)
+	}
+}
+
+func computeValues(op Token, left, right interface{}) (interface{}, error) {
+	if op.Type == Add {
+		s1, ok1 := left.(string)
+		s2, ok2 := right.(string)
+		if ok1 || ok2 {
+			if !ok1 {
+				s1 = toString(left)
+			}
+			if !ok2 {
+				s2 = toString(right)
+			}
+			return s1 + s2, nil
+		}
+	}
+	if x, ok := toInt(left); ok {
+		if y, ok := toInt(right); ok {
+			return computeInt(op, x, y)
+		}
+	}
+	x, ok1 := toFloat(left)
+	y, ok2 := toFloat(right)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%s: unsupported operand types %T and %T", op.Literal, left, right)
+	}
+	return computeFloat(op, x, y)
+}
+
+func computeInt(op Token, x, y int64) (interface{}, error) {
+	switch op.Type {
+	case Add:
+		return x + y, nil
+	case Sub:
+		return x - y, nil
+	case Mul:
+		return x * y, nil
+	case Div:
+		if y == 0 {
+			return nil, errZero
+		}
+		return x / y, nil
+	case Mod:
+		if y == 0 {
+			return nil, errZero
+		}
+		return x % y, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported operator", op.Literal)
+	}
+}
+
+func computeFloat(op Token, x, y float64) (interface{}, error) {
+	switch op.Type {
+	case Add:
+		return x + y, nil
+	case Sub:
+		return x - y, nil
+	case Mul:
+		return x * y, nil
+	case Div:
+		if y == 0 {
+			return nil, errZero
+		}
+		return x / y, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported operator for float", op.Literal)
+	}
+}
+
+func compareValues(op Token, left, right interface{}) (bool, error) {
+	var cmp int
+	if s1, ok := left.(string); ok {
+		s2, ok := right.(string)
+		if !ok {
+			return false, fmt.Errorf("%s: string can not be compared to %T", op.Literal, right)
+		}
+		cmp = strings.Compare(s1, s2)
+	} else {
+		x, ok1 := toFloat(left)
+		y, ok2 := toFloat(right)
+		if !ok1 || !ok2 {
+			return false, fmt.Errorf("%s: %T and %T can not be compared", op.Literal, left, right)
+		}
+		switch {
+		case x < y:
+			cmp = -1
+		case x > y:
+			cmp = 1
+		}
+	}
+	switch op.Type {
+	case Lt:
+		return cmp < 0, nil
+	case Le:
+		return cmp <= 0, nil
+	case Gt:
+		return cmp > 0, nil
+	case Ge:
+		return cmp >= 0, nil
+	default:
+		return false, fmt.Errorf("%s: unsupported operator", op.Literal)
+	}
+}
+
+func isEqual(left, right interface{}) bool {
+	if x, ok := toFloat(left); ok {
+		y, ok := toFloat(right)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	default:
+		if i, ok := toInt(v); ok {
+			return i != 0, nil
+		}
+		return false, fmt.Errorf("boolean expected! got %T", v)
+	}
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch v := reflect.ValueOf(v); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), true
+	default:
+		return 0, false
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	if i, ok := toInt(v); ok {
+		return float64(i), true
+	}
+	switch v := reflect.ValueOf(v); v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
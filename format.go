@@ -0,0 +1,18 @@
+package fig
+
+import (
+	"io"
+)
+
+func Format(r io.Reader, w io.Writer) error {
+	p, err := NewParser(r)
+	if err != nil {
+		return err
+	}
+	p.raw = true
+	n, err := p.Parse()
+	if err != nil {
+		return err
+	}
+	return writeNode(w, n)
+}
@@ -0,0 +1,111 @@
+package fig_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/midbel/fig"
+)
+
+func ExampleFormat() {
+	const demo = `
+name = demo
+ttl = 30m;
+server alpha {
+addr = "192.168.67.181"
+	backup= ['10.100.0.1',
+	"10.100.0.2",
+	]
+  cmd = ` + "`echo ${name}`" + `
+}
+
+.define(base) {
+	enable=true
+}
+.apply(base, fields = [enable], method=merge)
+first = $array[:3]
+	`
+	if err := fig.Format(strings.NewReader(demo), os.Stdout); err != nil {
+		fmt.Printf("fail to format demo: %s\n", err)
+		return
+	}
+	// Output:
+	// name = demo
+	// ttl  = 30m
+	// server alpha {
+	//   addr   = "192.168.67.181"
+	//   backup = ["10.100.0.1", "10.100.0.2"]
+	//   cmd    = `echo ${name}`
+	// }
+	//
+	// .define(base) {
+	//   enable = true
+	// }
+	// .apply(base, fields=[enable], method=merge)
+	// first = $array[:3]
+}
+
+func ExampleFormat_comments() {
+	const demo = `
+# doc comment of name
+name = demo # inline comment
+
+# free floating comment
+
+ports = [
+	# leading comment
+	80, # http
+	443,
+]
+server {
+	addr = "192.168.67.181"
+	# last comment in object
+} # after object
+	`
+	if err := fig.Format(strings.NewReader(demo), os.Stdout); err != nil {
+		fmt.Printf("fail to format demo: %s\n", err)
+		return
+	}
+	// Output:
+	// # doc comment of name
+	// name = demo # inline comment
+	//
+	// # free floating comment
+	//
+	// ports = [
+	//   # leading comment
+	//   80, # http
+	//   443,
+	// ]
+	// server {
+	//   addr = "192.168.67.181"
+	//   # last comment in object
+	// } # after object
+}
+
+func ExampleFormat_verbatim() {
+	const demo = `
+text = <<EOF
+    indented first line
+  second line
+EOF
+mask = 0xdead_beaf
+size = 1_000_000
+ok = ($a && $b) || !true
+sum = (1 + 2) * 3
+	`
+	if err := fig.Format(strings.NewReader(demo), os.Stdout); err != nil {
+		fmt.Printf("fail to format demo: %s\n", err)
+		return
+	}
+	// Output:
+	// text = <<EOF
+	//     indented first line
+	//   second line
+	// EOF
+	// mask = 0xdead_beaf
+	// size = 1_000_000
+	// ok   = ($a && $b) || !true
+	// sum  = (1 + 2) * 3
+}
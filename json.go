@@ -0,0 +1,248 @@
+package fig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+func ToJSON(r io.Reader, w io.Writer) error {
+	return NewDecoder(r).DecodeJSON(w)
+}
+
+func FromJSON(r io.Reader, w io.Writer) error {
+	n, err := ParseJSON(r)
+	if err != nil {
+		return err
+	}
+	return writeNode(w, n)
+}
+
+func (d *Decoder) DecodeJSON(w io.Writer) error {
+	p, err := d.parser()
+	if err != nil {
+		return err
+	}
+	n, err := p.Parse()
+	if err != nil {
+		return err
+	}
+	obj, ok := n.(*object)
+	if !ok {
+		return fmt.Errorf("root node is not an object")
+	}
+	var buf bytes.Buffer
+	if err := d.decodeJSON(&buf, obj); err != nil {
+		var de *DecodeError
+		if errors.As(err, &de) {
+			de.File = p.file
+			de.Snippet = makeSnippet(p.scan.input, de.Line, de.Column)
+		}
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", indent); err != nil {
+		return err
+	}
+	out.WriteString("\n")
+	_, err = io.Copy(w, &out)
+	return err
+}
+
+func (d *Decoder) decodeJSON(buf *bytes.Buffer, obj *object) error {
+	if err := d.registerObject(obj); err != nil {
+		return err
+	}
+	d.push()
+	defer d.pop()
+
+	buf.WriteString("{")
+	var count int
+	for i, n := range obj.Nodes {
+		if n.Type() == TypeComment {
+			continue
+		}
+		if count > 0 {
+			buf.WriteString(",")
+		}
+		count++
+		if err := writeJSON(buf, obj.Revex[i]); err != nil {
+			return err
+		}
+		buf.WriteString(":")
+
+		var err error
+		switch n := n.(type) {
+		case *object:
+			err = d.decodeJSON(buf, n)
+		case *option:
+			var (
+				val interface{}
+				vf  = reflect.ValueOf(&val).Elem()
+			)
+			if err = d.decodeOption(n, vf); err == nil {
+				err = writeJSON(buf, val)
+			}
+		case *array:
+			buf.WriteString("[")
+			for j, n := range n.Nodes {
+				if j > 0 {
+					buf.WriteString(",")
+				}
+				nest, ok := n.(*object)
+				if !ok {
+					return notAnObject(obj.Revex[i])
+				}
+				if err = d.decodeJSON(buf, nest); err != nil {
+					break
+				}
+			}
+			buf.WriteString("]")
+		default:
+			err = fmt.Errorf("can not convert %T to json", n)
+		}
+		if err != nil {
+			return decodeError(joinPath(objectPath(obj), obj.Revex[i]), n.Pos(), err)
+		}
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err == nil {
+		buf.Write(b)
+	}
+	return err
+}
+
+func ParseJSON(r io.Reader) (Node, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("json: object expected at top level")
+	}
+	obj := createObject("root")
+	if err := parseJSONObject(dec, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func parseJSONObject(dec *json.Decoder, obj *object) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("json: string expected as key")
+		}
+		n, err := parseJSONValue(dec, key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		switch n := n.(type) {
+		case *object:
+			err = obj.set(n)
+		case *array:
+			if !isObjectList(n) {
+				err = obj.set(createOption(key, n))
+				break
+			}
+			if len(n.Nodes) == 1 {
+				if err = obj.set(createOption(key, createArray())); err != nil {
+					break
+				}
+			}
+			for _, n := range n.Nodes {
+				if err = obj.set(n); err != nil {
+					break
+				}
+			}
+		default:
+			err = obj.set(createOption(key, n))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+func parseJSONValue(dec *json.Decoder, key string) (Node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == json.Delim('{') {
+			obj := createObject(key)
+			return obj, parseJSONObject(dec, obj)
+		}
+		arr := createArray()
+		for dec.More() {
+			n, err := parseJSONValue(dec, key)
+			if err != nil {
+				return nil, err
+			}
+			if n == nil {
+				return nil, fmt.Errorf("json: null can not be used in array")
+			}
+			if a, ok := n.(*array); ok && isObjectList(a) {
+				return nil, fmt.Errorf("json: objects can not be used in nested arrays")
+			}
+			arr.Append(n)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		if !isObjectList(arr) {
+			for _, n := range arr.Nodes {
+				if n.Type() == TypeObject {
+					return nil, fmt.Errorf("json: objects can not be mixed with values in array")
+				}
+			}
+		}
+		return arr, nil
+	case string:
+		return createLiteralFromString(tok), nil
+	case json.Number:
+		kind := Integer
+		if strings.ContainsAny(tok.String(), ".eE") {
+			kind = Float
+		}
+		return createLiteral(makeToken(tok.String(), kind)), nil
+	case bool:
+		return createLiteral(makeToken(fmt.Sprint(tok), Boolean)), nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("json: unexpected token %v", tok)
+	}
+}
+
+func isObjectList(arr *array) bool {
+	if len(arr.Nodes) == 0 {
+		return false
+	}
+	for _, n := range arr.Nodes {
+		if n.Type() != TypeObject {
+			return false
+		}
+	}
+	return true
+}
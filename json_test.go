@@ -0,0 +1,126 @@
+package fig_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/midbel/fig"
+)
+
+func ExampleToJSON() {
+	const demo = `
+name = "demo"
+tpl  = ` + "`${name}-1.0`" + `
+server {
+  addr = "192.168.67.181"
+  port = [80, 443]
+}
+server {
+  addr = "192.168.67.236"
+  port = []
+}
+`
+	if err := fig.ToJSON(strings.NewReader(demo), os.Stdout); err != nil {
+		fmt.Printf("fail to convert document: %s\n", err)
+	}
+	// Output:
+	// {
+	//   "name": "demo",
+	//   "tpl": "demo-1.0",
+	//   "server": [
+	//     {
+	//       "addr": "192.168.67.181",
+	//       "port": [
+	//         80,
+	//         443
+	//       ]
+	//     },
+	//     {
+	//       "addr": "192.168.67.236",
+	//       "port": []
+	//     }
+	//   ]
+	// }
+}
+
+func ExampleFromJSON() {
+	const demo = `{
+		"name": "demo",
+		"ratio": 0.5,
+		"opts": null,
+		"tags": ["a", "b"],
+		"server": [{"addr": "alpha"}, {"addr": "omega"}],
+		"backup": [{"addr": "beta"}],
+		"meta": {"vcs": "git"}
+	}`
+	var buf bytes.Buffer
+	if err := fig.FromJSON(strings.NewReader(demo), &buf); err != nil {
+		fmt.Printf("fail to convert document: %s\n", err)
+		return
+	}
+	fmt.Print(buf.String())
+	if err := fig.ToJSON(&buf, os.Stdout); err != nil {
+		fmt.Printf("fail to convert document: %s\n", err)
+	}
+	// Output:
+	// name  = "demo"
+	// ratio = 0.5
+	// opts  =
+	// tags  = ["a", "b"]
+	// server {
+	//   addr = "alpha"
+	// }
+	// server {
+	//   addr = "omega"
+	// }
+	// backup = []
+	// backup {
+	//   addr = "beta"
+	// }
+	// meta {
+	//   vcs = "git"
+	// }
+	// {
+	//   "name": "demo",
+	//   "ratio": 0.5,
+	//   "opts": null,
+	//   "tags": [
+	//     "a",
+	//     "b"
+	//   ],
+	//   "server": [
+	//     {
+	//       "addr": "alpha"
+	//     },
+	//     {
+	//       "addr": "omega"
+	//     }
+	//   ],
+	//   "backup": [
+	//     {
+	//       "addr": "beta"
+	//     }
+	//   ],
+	//   "meta": {
+	//     "vcs": "git"
+	//   }
+	// }
+}
+
+func ExampleToJSON_errors() {
+	const demo = `
+outer {
+  inner = missing(1)
+}
+`
+	err := fig.ToJSON(strings.NewReader(demo), os.Stdout)
+	fmt.Println(err)
+
+	err = fig.FromJSON(strings.NewReader(`{"n": [[{"x": 1}]]}`), os.Stdout)
+	fmt.Println(err)
+	// Output:
+	// 3:3: outer.inner: missing: undefined function
+	// n: json: objects can not be used in nested arrays
+}
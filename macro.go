@@ -2,14 +2,18 @@ package fig
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"io/fs"
 	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -19,15 +23,122 @@ type macroFunc func(root, obj Node, env *Env, args []Node, kwargs map[string]Nod
 type macrodef struct {
 	macroFunc
 	withobject bool
+	params     []string
 }
 
-func createMacroDef(fn macroFunc, with bool) macrodef {
+func createMacroDef(fn macroFunc, with bool, params ...string) macrodef {
 	return macrodef{
 		macroFunc:  fn,
 		withobject: with,
+		params:     params,
 	}
 }
 
+type MacroInfo struct {
+	Name   string
+	Params []string
+	Block  bool
+}
+
+type MacroFunc func(*MacroContext) error
+
+type MacroContext struct {
+	name   string
+	root   *object
+	nest   Node
+	call   macrocall
+	args   []Node
+	kwargs map[string]Node
+}
+
+func wrapMacro(name string, fn MacroFunc) macroFunc {
+	return func(root, nest Node, env *Env, args []Node, kwargs map[string]Node) error {
+		obj, ok := root.(*object)
+		if !ok {
+			return fmt.Errorf("root should be an object! got %T", root)
+		}
+		ctx := MacroContext{
+			name:   name,
+			root:   obj,
+			nest:   nest,
+			call:   callMacro(root, env),
+			args:   args,
+			kwargs: kwargs,
+		}
+		if err := fn(&ctx); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+}
+
+func (c *MacroContext) Name() string {
+	return c.name
+}
+
+func (c *MacroContext) Len() int {
+	return len(c.args)
+}
+
+func (c *MacroContext) Has(at int, field string) bool {
+	_, err := checkHas(at+1, field, c.args, c.kwargs)
+	return err == nil
+}
+
+func (c *MacroContext) GetString(at int, field string) (string, error) {
+	return c.call.GetString(at, field, c.args, c.kwargs)
+}
+
+func (c *MacroContext) GetInt(at int, field string) (int64, error) {
+	return c.call.GetInt(at, field, c.args, c.kwargs)
+}
+
+func (c *MacroContext) GetBool(at int, field string) (bool, error) {
+	return c.call.GetBool(at, field, c.args, c.kwargs)
+}
+
+func (c *MacroContext) GetStringArray(at int, field string) ([]string, error) {
+	return c.call.GetStringArray(at, field, c.args, c.kwargs)
+}
+
+func (c *MacroContext) Block() ObjectNode {
+	obj, ok := c.nest.(*object)
+	if !ok {
+		return nil
+	}
+	return obj
+}
+
+func (c *MacroContext) Set(key string, value interface{}) error {
+	n, err := encodeNode(key, value)
+	if err != nil {
+		return err
+	}
+	if obj, ok := n.(*object); ok {
+		obj.parent = c.root
+	}
+	return c.root.set(n)
+}
+
+func (c *MacroContext) Merge(n Node) error {
+	obj, ok := n.(*object)
+	if !ok {
+		return notAnObject("node")
+	}
+	return c.root.merge(obj.clone())
+}
+
+func (c *MacroContext) Append(name string, n Node) error {
+	obj, ok := n.(*object)
+	if !ok {
+		return notAnObject("node")
+	}
+	obj = obj.clone().(*object)
+	obj.Name = name
+	obj.parent = c.root
+	return c.root.insert(obj)
+}
+
 var errBadArgument = errors.New("argument")
 
 type strategy int
@@ -68,9 +179,16 @@ const (
 	argCount  = "count"
 	argKey    = "key"
 	argCmd    = "command"
+	argOnce   = "once"
+	argSum    = "sha256"
 )
 
-func Script(root, _ Node, env *Env, args []Node, kwargs map[string]Node) error {
+func Script(root, nest Node, env *Env, args []Node, kwargs map[string]Node) error {
+	var p Parser
+	return p.script(root, nest, env, args, kwargs)
+}
+
+func (p *Parser) script(root, _ Node, env *Env, args []Node, kwargs map[string]Node) error {
 	var (
 		mcall = callMacro(root, env)
 		key   string
@@ -83,6 +201,9 @@ func Script(root, _ Node, env *Env, args []Node, kwargs map[string]Node) error {
 	if cmd, err = mcall.GetString(1, argCmd, args, kwargs); err != nil {
 		return err
 	}
+	if err := p.policy.allowCommand(cmd); err != nil {
+		return err
+	}
 	out, err := exec.Command("sh", "-c", cmd).Output()
 	if err != nil {
 		return err
@@ -205,7 +326,12 @@ func IfNotEq(root, nest Node, env *Env, args []Node, kwargs map[string]Node) err
 	return nil
 }
 
-func ReadFile(root, _ Node, env *Env, args []Node, kwargs map[string]Node) error {
+func ReadFile(root, nest Node, env *Env, args []Node, kwargs map[string]Node) error {
+	p := Parser{limit: maxIncludeDepth}
+	return p.readFile(root, nest, env, args, kwargs)
+}
+
+func (p *Parser) readFile(root, _ Node, env *Env, args []Node, kwargs map[string]Node) error {
 	if len(args) == 0 && len(kwargs) == 0 {
 		return fmt.Errorf("no enough arguments supplied")
 	}
@@ -227,7 +353,11 @@ func ReadFile(root, _ Node, env *Env, args []Node, kwargs map[string]Node) error
 			name = strings.TrimSuffix(name, ext)
 		}
 	}
-	content, err := os.ReadFile(file)
+	file = p.resolvePath(file)
+	if err := p.allowPath(file); err != nil {
+		return err
+	}
+	content, err := fs.ReadFile(p.filesystem(), file)
 	if err != nil {
 		return err
 	}
@@ -355,13 +485,20 @@ func Apply(root, _ Node, env *Env, args []Node, kwargs map[string]Node) error {
 	return nil
 }
 
-func Include(root, _ Node, env *Env, args []Node, kwargs map[string]Node) error {
+func Include(root, nest Node, env *Env, args []Node, kwargs map[string]Node) error {
+	p := Parser{limit: maxIncludeDepth}
+	return p.include(root, nest, env, args, kwargs)
+}
+
+func (p *Parser) include(root, _ Node, env *Env, args []Node, kwargs map[string]Node) error {
 	var (
 		mcall  = callMacro(root, env)
 		file   string
 		name   string
 		method string
 		fatal  bool
+		once   bool
+		sum    string
 		err    error
 	)
 
@@ -377,41 +514,113 @@ func Include(root, _ Node, env *Env, args []Node, kwargs map[string]Node) error
 	if method, err = mcall.GetString(3, argMeth, args, kwargs); err != nil && !errors.Is(err, errBadArgument) {
 		return err
 	}
-	n, err := include(file, name, fatal)
-	if err != nil || n == nil {
+	if once, err = mcall.GetBool(4, argOnce, args, kwargs); err != nil && !errors.Is(err, errBadArgument) {
+		return err
+	}
+	if sum, err = mcall.GetString(5, argSum, args, kwargs); err != nil && !errors.Is(err, errBadArgument) {
+		return err
+	}
+	files, err := p.locate(file)
+	if err != nil {
 		return err
 	}
+	if len(files) == 0 {
+		if !fatal {
+			return nil
+		}
+		return fmt.Errorf("%s: no file matches", file)
+	}
 	obj, ok := root.(*object)
 	if !ok {
 		return fmt.Errorf("root should be an object! got %T", root)
 	}
-	switch do := strategyFromString(method); do {
-	case sReplace:
-		err = obj.replace(n)
-	case sAppend:
-		err = obj.insert(n)
-	case sMerge:
-		err = obj.merge(n)
-	default:
-		err = fmt.Errorf("unknown/unsupported insertion method supplied")
+	do := strategyFromString(method)
+	for _, f := range files {
+		n, err := p.includeFile(f, name, sum, fatal, once)
+		if err != nil {
+			return err
+		}
+		if n == nil {
+			continue
+		}
+		switch do {
+		case sReplace:
+			err = obj.replace(n)
+		case sAppend:
+			err = obj.insert(n)
+		case sMerge:
+			err = obj.merge(n)
+		default:
+			err = fmt.Errorf("unknown/unsupported insertion method supplied")
+		}
+		if err != nil {
+			return err
+		}
 	}
-	return err
+	return nil
 }
 
-func include(file, name string, fatal bool) (Node, error) {
-	var (
-		u, _ = url.Parse(file)
-		rc   io.ReadCloser
-		err  error
-	)
-	switch u.Scheme {
-	case "", "file":
-		rc, err = readFile(file)
-	case "http", "https":
-		rc, err = readRemote(file)
+func (p *Parser) locate(file string) ([]string, error) {
+	switch schemeOf(file) {
+	case "file":
+		u, _ := url.Parse(file)
+		file = u.Path
+	case "":
 	default:
-		err = fmt.Errorf("%s: unsupported scheme (%s)", u.Scheme, file)
+		return []string{file}, nil
+	}
+	file = p.resolvePath(file)
+	if schemeOf(file) != "" {
+		return []string{file}, nil
 	}
+	if !hasMeta(file) {
+		i, err := fs.Stat(p.filesystem(), file)
+		if err != nil || !i.IsDir() {
+			return []string{file}, nil
+		}
+		file = p.joinPath(file, "*.fig")
+	}
+	list, err := fs.Glob(p.filesystem(), file)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func schemeOf(file string) string {
+	u, err := url.Parse(file)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme
+}
+
+func hasMeta(file string) bool {
+	return strings.ContainsAny(file, "*?[")
+}
+
+func (p *Parser) includeFile(file, name, sum string, fatal, once bool) (Node, error) {
+	if err := p.allowPath(file); err != nil {
+		return nil, err
+	}
+	open := p.openFile
+	if scheme := schemeOf(file); scheme != "" {
+		if _, ok := p.schemeHandler(scheme); !ok {
+			if !fatal {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("%s: unsupported scheme (%s)", scheme, file)
+		}
+		open = p.openURL
+	}
+	if once && p.isIncluded(file) {
+		return nil, nil
+	}
+	if err := p.enter(file); err != nil {
+		return nil, err
+	}
+	rc, err := open(file)
 	if err != nil {
 		if !fatal {
 			err = nil
@@ -420,13 +629,27 @@ func include(file, name string, fatal bool) (Node, error) {
 	}
 	defer rc.Close()
 
-	node, err := Parse(rc)
+	buf, err := io.ReadAll(rc)
 	if err != nil {
 		if !fatal {
 			err = nil
 		}
 		return nil, err
 	}
+	if err := verifySum(file, sum, buf); err != nil {
+		return nil, err
+	}
+	sub, err := p.fork(bytes.NewReader(buf), file)
+	if err != nil {
+		return nil, err
+	}
+	node, err := sub.Parse()
+	if err != nil {
+		if !fatal && !errors.Is(err, ErrCycle) && !errors.Is(err, ErrDepth) && !errors.Is(err, ErrIntegrity) && !errors.Is(err, ErrAllow) {
+			err = nil
+		}
+		return nil, err
+	}
 	obj, ok := node.(*object)
 	if ok {
 		obj.Name = filepath.Base(file)
@@ -437,26 +660,157 @@ func include(file, name string, fatal bool) (Node, error) {
 	return node, nil
 }
 
-func readFile(file string) (io.ReadCloser, error) {
-	return os.Open(file)
+func verifySum(file, sum string, buf []byte) error {
+	if sum == "" {
+		return nil
+	}
+	got := sha256.Sum256(buf)
+	if str := hex.EncodeToString(got[:]); !strings.EqualFold(str, sum) {
+		return fmt.Errorf("%w: %s: sha256 mismatch (want %s, got %s)", ErrIntegrity, file, sum, str)
+	}
+	return nil
 }
 
-func readRemote(file string) (io.ReadCloser, error) {
-	res, err := http.Get(file)
+func (p *Parser) fork(r io.Reader, file string) (*Parser, error) {
+	sub, err := NewParser(r)
 	if err != nil {
 		return nil, err
 	}
-	if res.StatusCode >= http.StatusBadRequest {
-		return nil, fmt.Errorf(res.Status)
+	sub.file = file
+	sub.fsys = p.fsys
+	sub.schemes = p.schemes
+	sub.policy = p.policy
+	sub.custom = p.custom
+	for k, def := range p.custom {
+		if def.macroFunc == nil {
+			delete(sub.macros, k)
+			continue
+		}
+		sub.macros[k] = def
+	}
+	sub.env = EmptyEnv()
+	sub.depth = p.depth + 1
+	sub.limit = p.limit
+	sub.includes = append(append([]string{}, p.trace()...), file)
+	sub.included = p.included
+	return sub, nil
+}
+
+func (p *Parser) enter(file string) error {
+	stack := p.trace()
+	for i, f := range stack {
+		if p.canonical(f) == p.canonical(file) {
+			list := append(append([]string{}, stack[i:]...), file)
+			return fmt.Errorf("%w: %s", ErrCycle, strings.Join(list, " -> "))
+		}
+	}
+	if p.depth >= p.limit {
+		return fmt.Errorf("%w (%d): %s", ErrDepth, p.limit, file)
+	}
+	if p.included == nil {
+		p.included = make(map[string]struct{})
+		for _, f := range stack {
+			p.included[p.canonical(f)] = struct{}{}
+		}
+	}
+	p.included[p.canonical(file)] = struct{}{}
+	return nil
+}
+
+func (p *Parser) isIncluded(file string) bool {
+	if p.included == nil && p.file != "" {
+		return p.canonical(p.file) == p.canonical(file)
+	}
+	_, ok := p.included[p.canonical(file)]
+	return ok
+}
+
+func (p *Parser) trace() []string {
+	if len(p.includes) == 0 && p.file != "" {
+		return []string{p.file}
 	}
-	return res.Body, nil
+	return p.includes
 }
 
+func (p *Parser) resolvePath(file string) string {
+	if schemeOf(p.file) != "" {
+		base, _ := url.Parse(p.file)
+		ref, err := url.Parse(file)
+		if err != nil || base.Opaque != "" {
+			return file
+		}
+		return base.ResolveReference(ref).String()
+	}
+	if p.fsys != nil {
+		if path.IsAbs(file) {
+			return strings.TrimPrefix(path.Clean(file), "/")
+		}
+		return path.Join(path.Dir(p.file), file)
+	}
+	if p.file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(filepath.Dir(p.file), file)
+}
+
+func (p *Parser) joinPath(dir, file string) string {
+	if p.fsys != nil {
+		return path.Join(dir, file)
+	}
+	return filepath.Join(dir, file)
+}
+
+func (p *Parser) canonical(file string) string {
+	if s := schemeOf(file); s != "" && s != "file" {
+		return file
+	}
+	if p.fsys != nil {
+		return path.Clean(file)
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.Clean(file)
+	}
+	return abs
+}
+
+func (p *Parser) filesystem() fs.FS {
+	if p.fsys == nil {
+		return osFS{}
+	}
+	return p.fsys
+}
+
+func (p *Parser) openFile(file string) (io.ReadCloser, error) {
+	return p.filesystem().Open(file)
+}
+
+type osFS struct{}
+
+func (osFS) Open(file string) (fs.File, error) {
+	return os.Open(file)
+}
+
+func (osFS) ReadFile(file string) ([]byte, error) {
+	return os.ReadFile(file)
+}
+
+func (osFS) Stat(file string) (fs.FileInfo, error) {
+	return os.Stat(file)
+}
+
+func (osFS) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+const maxDepth = 64
+
 type macrocall struct {
 	args   []Node
 	kwargs map[string]Node
 	root   Node
 	env    *Env
+	depth  int
 }
 
 func callMacro(root Node, env *Env) macrocall {
@@ -466,6 +820,39 @@ func callMacro(root Node, env *Env) macrocall {
 	}
 }
 
+func (c macrocall) resolveVariable(v *variable) (interface{}, error) {
+	if !v.IsLocal() {
+		return c.env.resolve(v.Name())
+	}
+	if c.depth >= maxDepth {
+		return nil, fmt.Errorf("%s: too many nested references", v.Name())
+	}
+	obj, _ := c.root.(*object)
+	for ; obj != nil; obj = obj.parent {
+		i, ok := obj.Index[v.Name()]
+		if !ok {
+			continue
+		}
+		opt, ok := obj.Nodes[i].(*option)
+		if !ok || opt.Value == nil {
+			break
+		}
+		nest := c
+		nest.root = obj
+		nest.depth++
+		return eval(opt.Value, nest)
+	}
+	return nil, fmt.Errorf("%s: undefined option", v.Name())
+}
+
+func (c macrocall) callFunction(n *call, ev evaluator) (interface{}, error) {
+	fn, ok := c.env.function(n.Ident)
+	if !ok {
+		return nil, fmt.Errorf("%s: undefined function", n.Ident)
+	}
+	return callNative(fn, n, ev)
+}
+
 func (c macrocall) IsDefined(n Node) bool {
 	v, ok := n.(*variable)
 	if !ok {
@@ -566,9 +953,9 @@ func (c macrocall) getBool(n Node, field string) (bool, error) {
 	if ok {
 		return arg.GetBool()
 	}
-	b, ok := tryBoolFromVar(n, c.env, c.root)
-	if !ok {
-		return false, fmt.Errorf("%s: node can not be used argument", field)
+	b, err := tryBoolFromVar(n, c.env, c.root)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", field, err)
 	}
 	return b, nil
 }
@@ -578,9 +965,9 @@ func (c macrocall) getString(n Node, field string) (string, error) {
 	if ok {
 		return arg.GetString()
 	}
-	str, ok := tryStringFromVar(n, c.env, c.root)
-	if !ok {
-		return "", fmt.Errorf("%s: node can not be used as argument", field)
+	str, err := tryStringFromVar(n, c.env, c.root)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
 	}
 	return str, nil
 }
@@ -590,9 +977,9 @@ func (c macrocall) getInt(n Node, field string) (int64, error) {
 	if ok {
 		return arg.GetInt()
 	}
-	num, ok := tryIntFromVar(n, c.env, c.root)
-	if !ok {
-		return 0, fmt.Errorf("%s: node can not be used argument", field)
+	num, err := tryIntFromVar(n, c.env, c.root)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", field, err)
 	}
 	return num, nil
 }
@@ -604,94 +991,78 @@ func (c macrocall) getArray(n Node, field string) (*array, error) {
 	}
 	arr, ok = tryFromVarArray(n, c.env, c.root)
 	if !ok {
-		return nil, fmt.Errorf("%s: node can not be used as argument", field)
+		return nil, fmt.Errorf("%s: %w", field, errNotArgument)
 	}
 	return arr, nil
 }
 
-func tryBoolFromVar(n Node, env *Env, root Node) (bool, bool) {
-	val, ok := tryFromVar(n, env, root)
-	if !ok {
-		return ok, ok
+var errNotArgument = errors.New("node can not be used as argument")
+
+func tryBoolFromVar(n Node, env *Env, root Node) (bool, error) {
+	val, err := tryFromVar(n, env, root)
+	if err != nil {
+		return false, err
 	}
-	var b bool
 	switch val := val.(type) {
-	default:
-		return false, false
 	case string:
-		x, err := strconv.ParseBool(val)
-		if err != nil {
-			return false, false
-		}
-		b = x
+		return strconv.ParseBool(val)
 	case int64:
-		b = val != 0
+		return val != 0, nil
 	case bool:
-		b = val
+		return val, nil
+	default:
+		return false, fmt.Errorf("%T can not be used as bool", val)
 	}
-	return b, true
 }
 
-func tryIntFromVar(n Node, env *Env, root Node) (int64, bool) {
-	val, ok := tryFromVar(n, env, root)
-	if !ok {
-		return 0, ok
+func tryIntFromVar(n Node, env *Env, root Node) (int64, error) {
+	val, err := tryFromVar(n, env, root)
+	if err != nil {
+		return 0, err
 	}
-	var num int64
 	switch val := val.(type) {
-	default:
-		return 0, false
 	case string:
-		n, err := strconv.ParseInt(val, 0, 64)
-		if err != nil {
-			return 0, false
-		}
-		num = n
+		return strconv.ParseInt(val, 0, 64)
 	case int64:
-		num = val
+		return val, nil
+	case float64:
+		return int64(val), nil
 	case bool:
 		if val {
-			num = 1
+			return 1, nil
 		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("%T can not be used as int", val)
 	}
-	return num, ok
 }
 
-func tryStringFromVar(n Node, env *Env, root Node) (string, bool) {
-	val, ok := tryFromVar(n, env, root)
-	if !ok {
-		return "", ok
+func tryStringFromVar(n Node, env *Env, root Node) (string, error) {
+	val, err := tryFromVar(n, env, root)
+	if err != nil {
+		return "", err
 	}
-	var str string
 	switch val := val.(type) {
-	default:
-		return "", false
 	case string:
-		str = val
+		return val, nil
 	case int64:
-		str = strconv.FormatInt(val, 10)
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
 	case bool:
-		str = strconv.FormatBool(val)
+		return strconv.FormatBool(val), nil
+	default:
+		return "", fmt.Errorf("%T can not be used as string", val)
 	}
-	return str, true
 }
 
-func tryFromVar(n Node, env *Env, root Node) (interface{}, bool) {
-	v, ok := n.(*variable)
-	if !ok {
-		return nil, ok
-	}
-	var val interface{}
-	if v.IsLocal() {
-		val, ok = resolveFromNode(root, v.Name())
-	} else {
-		v, err := env.resolve(v.Name())
-		if err != nil {
-			return 0, false
-		}
-		val = v
+func tryFromVar(n Node, env *Env, root Node) (interface{}, error) {
+	switch n.(type) {
+	case *variable, *call, *unary, *binary, *ternary:
+	default:
+		return nil, errNotArgument
 	}
-	return val, ok
+	return eval(n, callMacro(root, env))
 }
 
 func tryFromVarArray(n Node, env *Env, root Node) (*array, bool) {
@@ -716,18 +1087,6 @@ func tryFromVarArray(n Node, env *Env, root Node) (*array, bool) {
 	return nil, false
 }
 
-func resolveFromNode(node Node, ident string) (interface{}, bool) {
-	n, ok := node.(*object)
-	if !ok {
-		return nil, ok
-	}
-	v, err := n.resolve(ident)
-	if err != nil {
-		return nil, false
-	}
-	return v, true
-}
-
 func checkHas(at int, field string, args []Node, kwargs map[string]Node) (Node, error) {
 	n, ok := kwargs[field]
 	if len(args) < at && !ok {
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
+	"sort"
 	"strconv"
 )
 
@@ -11,16 +14,52 @@ var (
 	ErrUnexpected = errors.New("unexpected token")
 	ErrSyntax     = errors.New("syntax error")
 	ErrAllow      = errors.New("not allowed")
+	ErrCycle      = errors.New("include cycle")
+	ErrDepth      = errors.New("maximum include depth reached")
+	ErrIntegrity  = errors.New("integrity check failed")
 )
 
+const maxIncludeDepth = 16
+
 type Parser struct {
 	scan *Scanner
 	curr Token
 	peek Token
 
-	env *Env
+	file     string
+	fsys     fs.FS
+	schemes  map[string]SchemeHandler
+	policy   *Policy
+	env      *Env
+	raw      bool
+	tolerant bool
+	errors   ErrorList
+	comment  *note
+	scope    *object
+
+	depth    int
+	limit    int
+	includes []string
+	included map[string]struct{}
 
 	macros map[string]macrodef
+	custom map[string]macrodef
+}
+
+func NewParserFS(fsys fs.FS, file string) (*Parser, error) {
+	r, err := fsys.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	p, err := NewParser(r)
+	if err != nil {
+		return nil, err
+	}
+	p.file = file
+	p.fsys = fsys
+	return p, nil
 }
 
 func NewParser(r io.Reader) (*Parser, error) {
@@ -31,20 +70,24 @@ func NewParser(r io.Reader) (*Parser, error) {
 
 	var p Parser
 	p.scan = sc
+	p.limit = maxIncludeDepth
+	if f, ok := r.(interface{ Name() string }); ok {
+		p.file = f.Name()
+	}
 	p.macros = map[string]macrodef{
-		"include":  createMacroDef(Include, false),
-		"define":   createMacroDef(Define, true),
-		"apply":    createMacroDef(Apply, false),
-		"extend":   createMacroDef(Extend, true),
-		"repeat":   createMacroDef(Repeat, true),
-		"readfile": createMacroDef(ReadFile, false),
+		"include":  createMacroDef(p.include, false, argFile, argName, argFatal, argMeth, argOnce, argSum),
+		"define":   createMacroDef(Define, true, argName, argMeth),
+		"apply":    createMacroDef(Apply, false, argName, argFields, argDepth, argMeth),
+		"extend":   createMacroDef(Extend, true, argName, argAs),
+		"repeat":   createMacroDef(Repeat, true, argCount, argName),
+		"readfile": createMacroDef(p.readFile, false, argFile, argName),
 		"ifeq":     createMacroDef(IfEq, true),
 		"ifneq":    createMacroDef(IfNotEq, true),
 		"ifdef":    createMacroDef(IfDef, true),
 		"ifndef":   createMacroDef(IfNotDef, true),
 		"register": createMacroDef(Register, false),
-		"script":   createMacroDef(Script, false),
-		"exec":     createMacroDef(Script, false),
+		"script":   createMacroDef(p.script, false, argKey, argCmd),
+		"exec":     createMacroDef(p.script, false, argKey, argCmd),
 	}
 	p.next()
 	p.next()
@@ -56,34 +99,135 @@ func Parse(r io.Reader) (Node, error) {
 	return ParseWithEnv(r, EmptyEnv())
 }
 
+func ParseFile(file string) (Node, error) {
+	return ParseFileWithEnv(file, EmptyEnv())
+}
+
+func ParseFileWithEnv(file string, env *Env) (Node, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ParseWithEnv(r, env)
+}
+
+func ParseFS(fsys fs.FS, file string) (Node, error) {
+	p, err := NewParserFS(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	p.env = EmptyEnv()
+	return p.Parse()
+}
+
 func ParseWithEnv(r io.Reader, env *Env) (Node, error) {
 	p, err := NewParser(r)
-	p.env = env
 	if err != nil {
 		return nil, err
 	}
+	p.env = env
 	return p.Parse()
 }
 
+func (p *Parser) Tolerant() {
+	p.tolerant = true
+}
+
+func (p *Parser) RegisterMacro(name string, fn MacroFunc, withObject bool) {
+	if p.custom == nil {
+		p.custom = make(map[string]macrodef)
+	}
+	if fn == nil {
+		p.custom[name] = macrodef{}
+		delete(p.macros, name)
+		return
+	}
+	def := createMacroDef(wrapMacro(name, fn), withObject)
+	p.custom[name] = def
+	p.macros[name] = def
+}
+
+func (p *Parser) UseFS(fsys fs.FS) {
+	p.fsys = fsys
+}
+
+func (p *Parser) MaxIncludeDepth(depth int) {
+	p.limit = depth
+}
+
+func (p *Parser) Raw() {
+	p.raw = true
+}
+
+func (p *Parser) Macros() []MacroInfo {
+	var list []MacroInfo
+	for k, m := range p.macros {
+		i := MacroInfo{
+			Name:   k,
+			Params: append([]string{}, m.params...),
+			Block:  m.withobject,
+		}
+		list = append(list, i)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
 func (p *Parser) Parse() (Node, error) {
 	for p.curr.isEOL() {
 		p.next()
 	}
 	obj := createObject("root")
 	if p.curr.Type == BegObj {
-		return obj, p.parseObject(obj)
+		if err := p.parseObject(obj); err != nil && !p.recover(err) {
+			return nil, p.parseError(err)
+		}
+		return p.result(obj)
 	}
 	for !p.done() {
 		if err := p.parse(obj); err != nil {
-			return nil, err
+			if !p.recover(err) {
+				return nil, p.parseError(err)
+			}
+			if p.curr.Type == EndObj {
+				p.next()
+				p.skip(EOL)
+			}
 		}
 	}
+	return p.result(obj)
+}
+
+func (p *Parser) result(obj *object) (Node, error) {
+	if len(p.errors) > 0 {
+		return obj, p.errors
+	}
 	return obj, nil
 }
 
+func (p *Parser) recover(err error) bool {
+	if !p.tolerant {
+		return false
+	}
+	if pe, ok := p.parseError(err).(*ParseError); ok {
+		p.errors = append(p.errors, pe)
+	}
+	for !p.done() && !p.curr.isEOL() && p.curr.Type != EndObj {
+		p.next()
+	}
+	if p.curr.isEOL() {
+		p.next()
+	}
+	return true
+}
+
 func (p *Parser) parse(obj *object) error {
+	p.scope = obj
 	if p.curr.isComment() {
-		p.parseComment()
+		p.parseComment(obj)
 		return nil
 	}
 	var ident Token
@@ -95,16 +239,21 @@ func (p *Parser) parse(obj *object) error {
 	default:
 		return p.unexpected()
 	}
+	if p.raw {
+		return p.parseRaw(obj)
+	}
+	comment := p.takeComment()
 	p.next()
 	var (
-		err error
-		n   Node
+		err  error
+		n    Node
+		nest *object
 	)
 	switch {
 	case p.curr.isIdent():
-		nest, err1 := obj.getObject(ident.Literal, false)
-		if err1 != nil {
-			return err1
+		nest, err = obj.getObject(ident.Literal, false)
+		if err != nil {
+			return err
 		}
 		for !p.done() {
 			if p.curr.Type == BegObj {
@@ -113,47 +262,159 @@ func (p *Parser) parse(obj *object) error {
 			if !p.curr.isIdent() {
 				return p.unexpected()
 			}
-			nest, err1 = nest.getObject(p.curr.Literal, p.peek.Type == BegObj)
-			if err1 != nil {
-				return err1
+			nest, err = nest.getObject(p.curr.Literal, p.peek.Type == BegObj)
+			if err != nil {
+				return err
 			}
 			p.next()
 		}
 		if p.curr.Type != BegObj {
 			return p.unexpected()
 		}
+		nest.pos = ident.Position
 		err = p.parseObject(nest)
+		n = nest
 	case p.curr.Type == BegObj:
-		nest, err1 := obj.getObject(ident.Literal, true)
-		if err1 != nil {
-			return err1
+		nest, err = obj.getObject(ident.Literal, true)
+		if err != nil {
+			return err
 		}
+		nest.pos = ident.Position
 		err = p.parseObject(nest)
+		n = nest
 	case p.curr.Type == Assign:
+		opt := createOption(ident.Literal, nil)
+		opt.pos = ident.Position
+		opt.eq = p.curr.Position
 		p.next()
-		n, err = p.parseValue()
-		if err == nil {
-			n = createOption(ident.Literal, n)
-			err = obj.set(n)
+		if opt.Value, err = p.parseValue(); err == nil {
+			opt.end = p.curr.Position
+			err = obj.set(opt)
+		}
+		n = opt
+	case p.curr.Type == BegGrp:
+		var fn *function
+		if fn, err = p.parseFunction(ident); err == nil {
+			err = obj.defineFunc(fn)
 		}
+		n = fn
+	default:
+		err = p.unexpected()
+	}
+	if err != nil {
+		return err
+	}
+	p.attachComment(n, comment)
+	return p.parseEOL()
+}
+
+func (p *Parser) parseRaw(obj *object) error {
+	var (
+		pos     = p.curr.Position
+		name    = p.curr.Literal
+		comment = p.takeComment()
+		err     error
+		n       Node
+	)
+	p.next()
+	switch {
+	case p.curr.isIdent() || p.curr.Type == BegObj:
+		nest := enclosedObject(name, obj)
+		for p.curr.isIdent() {
+			nest.Labels = append(nest.Labels, p.curr.Literal)
+			p.next()
+		}
+		if p.curr.Type != BegObj {
+			return p.unexpected()
+		}
+		nest.pos = pos
+		err = p.parseObject(nest)
+		n = nest
+	case p.curr.Type == Assign:
+		opt := createOption(name, nil)
+		opt.eq = p.curr.Position
+		p.next()
+		opt.Value, err = p.parseValue()
+		opt.pos = pos
+		opt.end = p.curr.Position
+		n = opt
+	case p.curr.Type == BegGrp:
+		n, err = p.parseFunction(Token{Literal: name, Position: pos})
 	default:
 		err = p.unexpected()
 	}
 	if err != nil {
 		return err
 	}
+	p.attachComment(n, comment)
+	obj.append(n)
 	return p.parseEOL()
 }
 
+func (p *Parser) parseFunction(ident Token) (*function, error) {
+	fn := createFunction(ident.Literal)
+	fn.pos = ident.Position
+	p.next()
+	for !p.done() && p.curr.Type != EndGrp {
+		if p.curr.Type != Ident || fn.has(p.curr.Literal) {
+			return nil, p.unexpected()
+		}
+		arg := Parameter{
+			Name: p.curr.Literal,
+		}
+		p.next()
+		if p.curr.Type == Assign {
+			p.next()
+			def, err := p.parseExpr(powLowest)
+			if err != nil {
+				return nil, err
+			}
+			arg.Default = def
+		} else if n := len(fn.Params); n > 0 && fn.Params[n-1].Default != nil {
+			return nil, fmt.Errorf("%s: parameter without default value after keyword parameter", arg.Name)
+		}
+		fn.Params = append(fn.Params, arg)
+		switch p.curr.Type {
+		case Comma:
+			p.next()
+		case EndGrp:
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if p.curr.Type != EndGrp {
+		return nil, p.unexpected()
+	}
+	p.next()
+	if p.curr.Type != BegObj {
+		return nil, p.unexpected()
+	}
+	p.next()
+	p.skipBlank()
+	body, err := p.parseExpr(powLowest)
+	if err != nil {
+		return nil, err
+	}
+	fn.Body = body
+	p.skipBlank()
+	if p.curr.Type != EndObj {
+		return nil, p.unexpected()
+	}
+	fn.end = p.curr.Position
+	p.next()
+	return fn, nil
+}
+
+func (p *Parser) skipBlank() {
+	for p.curr.isEOL() || p.curr.isComment() {
+		p.next()
+	}
+}
+
 func (p *Parser) parseEOL() error {
 	switch p.curr.Type {
-	case EOL:
+	case EOL, Comment:
 		p.next()
-		if p.curr.isComment() {
-			p.parseComment()
-		}
-	case Comment:
-		p.parseComment()
 	case EOF:
 	default:
 		return p.unexpected()
@@ -161,7 +422,88 @@ func (p *Parser) parseEOL() error {
 	return nil
 }
 
+const (
+	powLowest = iota
+	powTernary
+	powOr
+	powAnd
+	powEq
+	powCmp
+	powAdd
+	powMul
+	powPrefix
+)
+
+var bindings = map[rune]int{
+	Ternary: powTernary,
+	Or:      powOr,
+	And:     powAnd,
+	Eq:      powEq,
+	Ne:      powEq,
+	Lt:      powCmp,
+	Le:      powCmp,
+	Gt:      powCmp,
+	Ge:      powCmp,
+	Add:     powAdd,
+	Sub:     powAdd,
+	Mul:     powMul,
+	Div:     powMul,
+	Mod:     powMul,
+}
+
 func (p *Parser) parseValue() (Node, error) {
+	if p.curr.isEOL() || p.curr.isComment() {
+		return nil, nil
+	}
+	return p.parseExpr(powLowest)
+}
+
+func (p *Parser) parseExpr(pow int) (Node, error) {
+	left, err := p.parsePrefix()
+	if err != nil {
+		return nil, err
+	}
+	for !p.done() && pow < bindings[p.curr.Type] {
+		if p.curr.Type == Ternary {
+			left, err = p.parseTernary(left)
+		} else {
+			left, err = p.parseBinary(left)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *Parser) parseBinary(left Node) (Node, error) {
+	op := p.curr
+	p.next()
+	right, err := p.parseExpr(bindings[op.Type])
+	if err != nil {
+		return nil, err
+	}
+	return createBinary(op, left, right), nil
+}
+
+func (p *Parser) parseTernary(cond Node) (Node, error) {
+	p.next()
+	csq, err := p.parseExpr(powLowest)
+	if err != nil {
+		return nil, err
+	}
+	if p.curr.Type != Slice {
+		return nil, p.unexpected()
+	}
+	p.next()
+	alt, err := p.parseExpr(powTernary - 1)
+	if err != nil {
+		return nil, err
+	}
+	return createTernary(cond, csq, alt), nil
+}
+
+func (p *Parser) parsePrefix() (Node, error) {
 	var (
 		n   Node
 		err error
@@ -172,6 +514,22 @@ func (p *Parser) parseValue() (Node, error) {
 		if err == nil {
 			n, err = p.parseSlice(n)
 		}
+	case p.curr.Type == BegGrp:
+		p.next()
+		if n, err = p.parseExpr(powLowest); err != nil {
+			break
+		}
+		if p.curr.Type != EndGrp {
+			return nil, p.unexpected()
+		}
+		setGroup(n)
+		p.next()
+	case p.curr.Type == Sub || p.curr.Type == Not:
+		op := p.curr
+		p.next()
+		if n, err = p.parseExpr(powPrefix); err == nil {
+			n = createUnary(op, n)
+		}
 	case p.curr.isTemplate():
 		n, err = p.parseTemplate()
 	case p.curr.isVariable():
@@ -189,8 +547,6 @@ func (p *Parser) parseValue() (Node, error) {
 			p.next()
 		}
 		n = i
-	case p.curr.isEOL():
-	case p.curr.isComment():
 	default:
 		return nil, p.unexpected()
 	}
@@ -215,6 +571,9 @@ func (p *Parser) parseTemplate() (Node, error) {
 		p.next()
 		t.Nodes = append(t.Nodes, n)
 	}
+	if p.done() {
+		return nil, p.parseError(fmt.Errorf("%w: unterminated template", ErrSyntax))
+	}
 	if !p.curr.isTemplate() {
 		return nil, p.unexpected()
 	}
@@ -223,13 +582,11 @@ func (p *Parser) parseTemplate() (Node, error) {
 }
 
 func (p *Parser) parseCall() (Node, error) {
-	var (
-		c   = createCall(p.curr.Literal)
-		err error
-	)
+	c := createCall(p.curr.Literal)
+	c.pos = p.curr.Position
+	c.scope = p.scope
 	p.next()
-	c.Args, c.Kwargs, err = p.parseArgs()
-	return c, err
+	return c, p.parseArgs(c)
 }
 
 func (p *Parser) parseObject(obj *object) error {
@@ -238,13 +595,14 @@ func (p *Parser) parseObject(obj *object) error {
 		if p.curr.Type == EndObj {
 			break
 		}
-		if err := p.parse(obj); err != nil {
+		if err := p.parse(obj); err != nil && !p.recover(err) {
 			return err
 		}
 	}
 	if p.curr.Type != EndObj {
 		return p.unexpected()
 	}
+	obj.end = p.curr.Position
 	p.next()
 	return nil
 }
@@ -254,55 +612,80 @@ func (p *Parser) parseArray() (Node, error) {
 		arr = createArray()
 		n   Node
 	)
+	arr.pos = p.curr.Position
 	p.next()
-	for !p.done() {
+	for closing := false; !p.done(); {
 		if p.curr.Type == EndArr {
 			break
 		}
+		var comment *note
 		if p.curr.isComment() {
-			p.parseComment()
+			comment = p.parseNote()
 		}
-		var err error
-		switch {
-		case p.curr.isLiteral() || p.curr.isVariable():
-			n, err = p.parseValue()
-		case p.curr.Type == BegArr:
-			n, err = p.parseArray()
-		default:
+		if p.curr.Type == EndArr {
+			p.attachArrayComment(arr, comment)
+			break
+		}
+		if closing {
 			return nil, p.unexpected()
 		}
-		if err != nil {
+		var err error
+		if n, err = p.parseExpr(powLowest); err != nil {
 			return nil, err
 		}
 		arr.Nodes = append(arr.Nodes, n)
 		switch p.curr.Type {
 		case Comment:
-			p.parseComment()
-			if p.curr.Type != EndArr {
-				return nil, p.unexpected()
-			}
+			p.attachComment(n, comment)
+			p.next()
+			closing = true
+			continue
 		case Comma:
+			line := p.curr.Line
 			p.next()
-			if p.curr.isComment() {
-				p.parseComment()
+			if p.curr.isComment() && p.curr.Line == line {
+				p.attachComment(n, comment)
+				p.next()
+				continue
 			}
 		case EndArr:
 		case EOL:
-			if p.peek.Type != EndArr {
-				return nil, p.unexpected()
-			}
 			p.next()
+			closing = true
 		default:
 			return nil, p.unexpected()
 		}
+		if comment != nil {
+			setComment(n, comment)
+		}
 	}
 	if p.curr.Type != EndArr {
 		return nil, p.unexpected()
 	}
+	arr.end = p.curr.Position
 	p.next()
 	return arr, nil
 }
 
+func (p *Parser) attachArrayComment(arr *array, c *note) {
+	if c == nil {
+		return
+	}
+	if len(arr.Nodes) == 0 {
+		arr.Comment = c
+		return
+	}
+	var (
+		last = arr.Nodes[len(arr.Nodes)-1]
+		prev = getComment(last)
+	)
+	if prev != nil {
+		prev.Tokens = append(prev.Tokens, c.Tokens...)
+		c = prev
+	}
+	setComment(last, c)
+}
+
 func (p *Parser) parseSlice(node Node) (Node, error) {
 	if p.curr.Type != BegArr {
 		return node, nil
@@ -327,7 +710,7 @@ func (p *Parser) parseSlice(node Node) (Node, error) {
 	}
 	switch p.curr.Type {
 	case EndArr:
-		slc.from = slc.to
+		slc.to = slc.from
 	case Slice:
 		p.next()
 	default:
@@ -353,6 +736,10 @@ func (p *Parser) parseSlice(node Node) (Node, error) {
 }
 
 func (p *Parser) parseMacro(obj *object) error {
+	var (
+		pos     = p.curr.Position
+		comment = p.takeComment()
+	)
 	p.next()
 	if p.curr.Type != Ident {
 		return p.unexpected()
@@ -362,13 +749,13 @@ func (p *Parser) parseMacro(obj *object) error {
 	if p.curr.Type != BegGrp {
 		return p.unexpected()
 	}
-	args, kwargs, err := p.parseArgs()
-	if err != nil {
+	c := createCall(ident.Literal)
+	if err := p.parseArgs(c); err != nil {
 		return err
 	}
 	def, ok := p.macros[ident.Literal]
 	if !ok {
-		return fmt.Errorf("%s: undefined macro", ident.Literal)
+		return p.errorAt(ident, fmt.Errorf("%s: undefined macro", ident.Literal))
 	}
 	var nest Node
 	if def.withobject {
@@ -381,20 +768,38 @@ func (p *Parser) parseMacro(obj *object) error {
 		}
 		nest = tmp
 	}
-	err = p.parseEOL()
-	if err != nil {
+	if p.raw {
+		m := createMacro(c, nest)
+		m.pos = pos
+		m.end = p.curr.Position
+		p.attachComment(m, comment)
+		obj.append(m)
+		return p.parseEOL()
+	}
+	if p.curr.isComment() {
+		if comment == nil {
+			comment = createNote()
+		}
+		comment.append(p.curr)
+	}
+	if comment != nil {
+		obj.append(comment)
+	}
+	if err := p.parseEOL(); err != nil {
 		return err
 	}
-	return def.macroFunc(obj, nest, p.env, args, kwargs)
+	if err := p.policy.allowMacro(ident.Literal); err != nil {
+		return p.errorAt(ident, err)
+	}
+	if err := def.macroFunc(obj, nest, p.env, c.Args, c.Kwargs); err != nil {
+		return p.errorAt(ident, err)
+	}
+	return nil
 }
 
-func (p *Parser) parseArgs() ([]Node, map[string]Node, error) {
+func (p *Parser) parseArgs(c *call) error {
 	p.next()
-	var (
-		named  bool
-		args   []Node
-		kwargs = make(map[string]Node)
-	)
+	var named bool
 	for !p.done() {
 		if p.curr.Type == EndGrp {
 			break
@@ -405,55 +810,107 @@ func (p *Parser) parseArgs() ([]Node, map[string]Node, error) {
 		if !named {
 			n, err := p.parseValue()
 			if err != nil {
-				return nil, nil, err
+				return err
 			}
-			args = append(args, n)
+			c.Args = append(c.Args, n)
 		} else {
 			if p.curr.Type != Ident {
-				return nil, nil, p.unexpected()
+				return p.unexpected()
 			}
-			if _, ok := kwargs[p.curr.Literal]; ok {
-				return nil, nil, p.unexpected()
+			if _, ok := c.Kwargs[p.curr.Literal]; ok {
+				return p.unexpected()
 			}
 			ident := p.curr
 			p.next()
 			if p.curr.Type != Assign {
-				return nil, nil, p.unexpected()
+				return p.unexpected()
 			}
 			p.next()
 			n, err := p.parseValue()
 			if err != nil {
-				return nil, nil, err
+				return err
 			}
-			kwargs[ident.Literal] = n
+			c.Kwargs[ident.Literal] = n
+			c.keys = append(c.keys, ident.Literal)
 		}
 		switch p.curr.Type {
 		case Comma:
 			p.next()
 		case EndGrp:
 		default:
-			return nil, nil, p.unexpected()
+			return p.unexpected()
 		}
 	}
 	if p.curr.Type != EndGrp {
-		return nil, nil, p.unexpected()
+		return p.unexpected()
 	}
 	p.next()
-	return args, kwargs, nil
+	return nil
 }
 
-func (p *Parser) parseComment() {
+func (p *Parser) parseComment(obj *object) {
+	c := p.parseNote()
+	if p.curr.Line == c.End().Line+1 && (p.curr.Type == Macro || p.curr.isIdent()) {
+		p.comment = c
+		return
+	}
+	obj.append(c)
+}
+
+func (p *Parser) parseNote() *note {
+	c := createNote()
 	for p.curr.isComment() {
+		c.append(p.curr)
 		p.next()
 	}
+	return c
+}
+
+func (p *Parser) takeComment() *note {
+	c := p.comment
+	p.comment = nil
+	return c
+}
+
+func (p *Parser) attachComment(n Node, c *note) {
+	if p.curr.isComment() {
+		if c == nil {
+			c = createNote()
+		}
+		c.append(p.curr)
+	}
+	if c != nil {
+		setComment(n, c)
+	}
 }
 
 func (p *Parser) unexpected() error {
-	return fmt.Errorf("parser error: %s %w: %s", p.curr.Position, ErrUnexpected, p.curr)
+	return p.parseError(fmt.Errorf("%w: %s", ErrUnexpected, p.curr))
 }
 
 func (p *Parser) syntaxError() error {
-	return fmt.Errorf("%w at %s", ErrSyntax, p.curr.Position)
+	return p.parseError(ErrSyntax)
+}
+
+func (p *Parser) parseError(err error) error {
+	return p.errorAt(p.curr, err)
+}
+
+func (p *Parser) errorAt(tok Token, err error) error {
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return err
+	}
+	pe = &ParseError{
+		File:   p.file,
+		Line:   tok.Line,
+		Column: tok.Col,
+		Token:  tok,
+		Path:   objectPath(p.scope),
+		Err:    err,
+	}
+	pe.Snippet = makeSnippet(p.scan.input, pe.Line, pe.Column)
+	return pe
 }
 
 func (p *Parser) done() bool {
@@ -1,7 +1,12 @@
 package fig_test
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/midbel/fig"
@@ -22,3 +27,181 @@ func TestParser(t *testing.T) {
 		t.Fatalf("fail to parse spec file: %s", err)
 	}
 }
+
+func TestParseFile(t *testing.T) {
+	n, err := fig.ParseFile("testdata/nested/main.fig")
+	if err != nil {
+		t.Fatalf("fail to parse nested file: %s", err)
+	}
+	var found bool
+	fig.Inspect(n, func(n fig.Node) bool {
+		if opt, ok := n.(fig.OptionNode); ok && opt.Key() == "data" {
+			lit, _ := opt.Expr().(fig.LiteralNode)
+			found = lit != nil && lit.Raw() == "hello"
+		}
+		return !found
+	})
+	if !found {
+		t.Fatalf("data.txt not read relative to the including file")
+	}
+}
+
+func TestParseFileCycle(t *testing.T) {
+	_, err := fig.ParseFile("testdata/cycle/a.fig")
+	if !errors.Is(err, fig.ErrCycle) {
+		t.Fatalf("include cycle expected! got %v", err)
+	}
+	want := "testdata/cycle/a.fig -> testdata/cycle/b.fig -> testdata/cycle/a.fig"
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("error should list the cycle %q! got %s", want, err)
+	}
+	if _, err := fig.ParseFile("testdata/once/main.fig"); err != nil {
+		t.Fatalf("fail to parse file with include once: %s", err)
+	}
+}
+
+func TestMaxIncludeDepth(t *testing.T) {
+	r, err := os.Open("testdata/nested/main.fig")
+	if err != nil {
+		t.Fatalf("fail to open file: %s", err)
+	}
+	defer r.Close()
+
+	p, err := fig.NewParser(r)
+	if err != nil {
+		t.Fatalf("fail to create parser: %s", err)
+	}
+	p.MaxIncludeDepth(0)
+	if _, err := p.Parse(); !errors.Is(err, fig.ErrDepth) {
+		t.Fatalf("maximum include depth error expected! got %v", err)
+	}
+
+	dec := fig.NewDecoderFS(os.DirFS("testdata/nested"), "main.fig")
+	dec.MaxIncludeDepth(0)
+	var v map[string]interface{}
+	if err := dec.Decode(&v); !errors.Is(err, fig.ErrDepth) {
+		t.Fatalf("maximum include depth error expected! got %v", err)
+	}
+	dec = fig.NewDecoderFS(os.DirFS("testdata/nested"), "main.fig")
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestIncludeGlob(t *testing.T) {
+	r, err := os.Open("testdata/confd/main.fig")
+	if err != nil {
+		t.Fatalf("fail to open file: %s", err)
+	}
+	defer r.Close()
+
+	var cfg struct {
+		Addr   string
+		Server []struct {
+			Addr string
+		}
+	}
+	if err := fig.NewDecoder(r).Decode(&cfg); err != nil {
+		t.Fatalf("fail to decode file: %s", err)
+	}
+	if cfg.Addr != "alpha" {
+		t.Errorf("addr: want alpha, got %s", cfg.Addr)
+	}
+	if len(cfg.Server) != 2 || cfg.Server[0].Addr != "alpha" || cfg.Server[1].Addr != "omega" {
+		t.Errorf("server: unexpected blocks %v", cfg.Server)
+	}
+	n, err := fig.Parse(strings.NewReader(`.include("testdata/confd/missing.d/*.fig", fatal=true)`))
+	if err == nil {
+		t.Fatalf("include with no match should fail when fatal! got %v", n)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Policy fig.Policy
+		Allow  bool
+	}{
+		{
+			Input:  `.exec(key=user, command="echo fig")`,
+			Policy: fig.Sandbox(),
+		},
+		{
+			Input:  `.script(key=user, command="echo fig")`,
+			Policy: fig.Policy{Macros: []string{"include"}},
+		},
+		{
+			Input:  `.exec(key=user, command="echo fig")`,
+			Policy: fig.Policy{Commands: []string{"echo"}},
+			Allow:  true,
+		},
+		{
+			Input:  `.exec(key=user, command="echo fig; id")`,
+			Policy: fig.Policy{Commands: []string{"echo"}},
+		},
+		{
+			Input:  `.exec(key=user, command="id")`,
+			Policy: fig.Policy{Commands: []string{"echo"}},
+		},
+		{
+			Input:  `.include("https://example.org/app.fig")`,
+			Policy: fig.Sandbox("testdata"),
+		},
+		{
+			Input:  `.include("https://example.org/app.fig")`,
+			Policy: fig.Policy{Hosts: []string{"*.example.com"}},
+		},
+		{
+			Input:  `.include("testdata/nested/main.fig", fatal=true)`,
+			Policy: fig.Sandbox("testdata/nested"),
+			Allow:  true,
+		},
+		{
+			Input:  `.include("testdata/nested/main.fig", fatal=true)`,
+			Policy: fig.Sandbox("testdata/nested/sub"),
+		},
+		{
+			Input:  `.include("testdata/nested/sub/child.fig")`,
+			Policy: fig.Policy{Macros: []string{"include"}},
+		},
+		{
+			Input:  `.readfile("testdata/nested/../nested/sub/data.txt")`,
+			Policy: fig.Sandbox("testdata/confd"),
+		},
+	}
+	for _, tt := range tests {
+		p, err := fig.NewParser(strings.NewReader(tt.Input))
+		if err != nil {
+			t.Fatalf("fail to create parser: %s", err)
+		}
+		p.UsePolicy(tt.Policy)
+		_, err = p.Parse()
+		if tt.Allow && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.Input, err)
+		}
+		if !tt.Allow && !errors.Is(err, fig.ErrAllow) {
+			t.Errorf("%s: not allowed error expected! got %v", tt.Input, err)
+		}
+	}
+}
+
+func TestPolicyJSON(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pwned")
+	doc := fmt.Sprintf(".exec(key=x, command=%q)\n", "touch "+file)
+
+	dec := fig.NewDecoder(strings.NewReader(doc))
+	dec.UsePolicy(fig.Sandbox())
+	if err := dec.DecodeJSON(io.Discard); !errors.Is(err, fig.ErrAllow) {
+		t.Errorf("not allowed error expected! got %v", err)
+	}
+	if _, err := os.Stat(file); err == nil {
+		t.Errorf("command should not have been executed")
+	}
+}
+
+func TestMacroArgumentError(t *testing.T) {
+	_, err := fig.Parse(strings.NewReader(".include(file=$missing)\n"))
+	if err == nil || !strings.Contains(err.Error(), "missing: undefined") {
+		t.Errorf("undefined variable error expected! got %v", err)
+	}
+}
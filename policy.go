@@ -0,0 +1,143 @@
+package fig
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type Policy struct {
+	Macros   []string
+	Deny     []string
+	Commands []string
+	Schemes  []string
+	Hosts    []string
+	Roots    []string
+}
+
+func Sandbox(roots ...string) Policy {
+	if roots == nil {
+		roots = []string{}
+	}
+	return Policy{
+		Deny:    []string{"script", "exec"},
+		Schemes: []string{},
+		Roots:   roots,
+	}
+}
+
+func (p *Parser) UsePolicy(pol Policy) {
+	p.policy = &pol
+}
+
+func (p *Policy) allowMacro(name string) error {
+	if p == nil {
+		return nil
+	}
+	if contains(p.Deny, name) || (p.Macros != nil && !contains(p.Macros, name)) {
+		return fmt.Errorf("%s: macro %w", name, ErrAllow)
+	}
+	return nil
+}
+
+const shellMeta = "|&;<>()$`\\\"'*?[]#~{}\n"
+
+func (p *Policy) allowCommand(cmd string) error {
+	if p == nil || p.Commands == nil {
+		return nil
+	}
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty command %w", ErrAllow)
+	}
+	if !contains(p.Commands, fields[0]) {
+		return fmt.Errorf("%s: command %w", fields[0], ErrAllow)
+	}
+	if strings.ContainsAny(cmd, shellMeta) {
+		return fmt.Errorf("%s: shell syntax %w in command", fields[0], ErrAllow)
+	}
+	return nil
+}
+
+func (p *Policy) allowURL(file string) error {
+	if p == nil {
+		return nil
+	}
+	u, err := url.Parse(file)
+	if err != nil {
+		return err
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if p.Schemes != nil && !contains(p.Schemes, scheme) {
+		return fmt.Errorf("%s: scheme %w (%s)", scheme, ErrAllow, file)
+	}
+	if p.Hosts == nil {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range p.Hosts {
+		h = strings.ToLower(h)
+		if h == host || (strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: host %w (%s)", host, ErrAllow, file)
+}
+
+func (p *Parser) allowPath(file string) error {
+	pol := p.policy
+	if pol == nil {
+		return nil
+	}
+	if s := schemeOf(file); s != "" && s != "file" {
+		return pol.allowURL(file)
+	}
+	if pol.Roots == nil {
+		return nil
+	}
+	for _, r := range pol.Roots {
+		if p.within(r, file) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: path %w", file, ErrAllow)
+}
+
+func (p *Parser) within(root, file string) bool {
+	if p.fsys != nil {
+		root = strings.TrimPrefix(path.Clean("/"+root), "/")
+		file = strings.TrimPrefix(path.Clean("/"+file), "/")
+		return root == "" || file == root || strings.HasPrefix(file, root+"/")
+	}
+	rel, err := filepath.Rel(realPath(root), realPath(file))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+func realPath(file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.Clean(file)
+	}
+	if res, err := filepath.EvalSymlinks(abs); err == nil {
+		return res
+	}
+	dir, base := filepath.Split(abs)
+	if dir = filepath.Clean(dir); dir == abs {
+		return abs
+	}
+	return filepath.Join(realPath(dir), base)
+}
+
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
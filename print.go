@@ -0,0 +1,604 @@
+package fig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	indent   = "  "
+	maxWidth = 80
+)
+
+type printer struct {
+	writer *bufio.Writer
+	base   string
+	level  int
+}
+
+func writeNode(w io.Writer, n Node) error {
+	obj, ok := n.(*object)
+	if !ok {
+		return fmt.Errorf("root node is not an object")
+	}
+	p := printer{
+		writer: bufio.NewWriter(w),
+	}
+	if err := p.printBody(obj); err != nil {
+		return err
+	}
+	return p.writer.Flush()
+}
+
+func (p *printer) printBody(obj *object) error {
+	for i := 0; i < len(obj.Nodes); i++ {
+		if i > 0 && hasBlank(obj.Nodes[i-1], obj.Nodes[i]) {
+			p.writer.WriteString("\n")
+		}
+		var err error
+		switch n := obj.Nodes[i].(type) {
+		case *option:
+			j := i + 1
+			for j < len(obj.Nodes) && isOption(obj.Nodes[j]) && !hasBlank(obj.Nodes[j-1], obj.Nodes[j]) {
+				j++
+			}
+			err = p.printOptions(obj.Nodes[i:j])
+			i = j - 1
+		case *object:
+			err = p.printObject(n)
+		case *macro:
+			err = p.printMacro(n)
+		case *function:
+			err = p.printFunction(n)
+		case *note:
+			p.printComments(n.Tokens)
+		case *array:
+			if len(n.Nodes) == 1 {
+				err = p.printOptions([]Node{createOption(obj.Revex[i], createArray())})
+			}
+			for _, a := range n.Nodes {
+				nest, ok := a.(*object)
+				if !ok {
+					return notAnObject(obj.Revex[i])
+				}
+				if err = p.printObject(nest); err != nil {
+					break
+				}
+			}
+		default:
+			err = fmt.Errorf("can not print %T", n)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *printer) printObject(obj *object) error {
+	before, inline, after := splitComment(obj)
+	p.printComments(before)
+	p.printIndent()
+	key, err := formatKey(obj.Name)
+	if err != nil {
+		return err
+	}
+	p.writer.WriteString(key)
+	for _, l := range obj.Labels {
+		if l, err = formatKey(l); err != nil {
+			return err
+		}
+		p.writer.WriteString(" ")
+		p.writer.WriteString(l)
+	}
+	if err := p.printBlock(obj); err != nil {
+		return err
+	}
+	p.printEOL(inline)
+	p.printComments(after)
+	return nil
+}
+
+func (p *printer) printMacro(m *macro) error {
+	before, inline, after := splitComment(m)
+	p.printComments(before)
+	p.printIndent()
+	p.writer.WriteString(".")
+	args, err := p.formatCall(m.call)
+	if err != nil {
+		return err
+	}
+	p.writer.WriteString(args)
+	if m.Body != nil {
+		obj, ok := m.Body.(*object)
+		if !ok {
+			return notAnObject(m.Ident)
+		}
+		if err := p.printBlock(obj); err != nil {
+			return err
+		}
+	}
+	p.printEOL(inline)
+	p.printComments(after)
+	return nil
+}
+
+func (p *printer) printFunction(fn *function) error {
+	before, inline, after := splitComment(fn)
+	p.printComments(before)
+	p.printIndent()
+
+	var list []string
+	for _, a := range fn.Params {
+		if a.Default == nil {
+			list = append(list, a.Name)
+			continue
+		}
+		str, err := p.formatValue(a.Default, 0)
+		if err != nil {
+			return err
+		}
+		list = append(list, a.Name+"="+str)
+	}
+	key, err := formatKey(fn.Ident)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(p.writer, "%s(%s) {\n", key, strings.Join(list, ", "))
+
+	p.level++
+	body, err := p.formatValue(fn.Body, 0)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn.Ident, err)
+	}
+	p.printIndent()
+	p.writer.WriteString(body)
+	p.writer.WriteString("\n")
+	p.level--
+
+	p.printIndent()
+	p.writer.WriteString("}")
+	p.printEOL(inline)
+	p.printComments(after)
+	return nil
+}
+
+func (p *printer) printBlock(obj *object) error {
+	if len(obj.Nodes) == 0 {
+		p.writer.WriteString(" {}")
+		return nil
+	}
+	p.writer.WriteString(" {\n")
+	p.level++
+	if err := p.printBody(obj); err != nil {
+		return err
+	}
+	p.level--
+	p.printIndent()
+	p.writer.WriteString("}")
+	return nil
+}
+
+func (p *printer) printComments(list []Token) {
+	for _, t := range list {
+		p.printIndent()
+		p.writer.WriteString(formatComment(t))
+		p.writer.WriteString("\n")
+	}
+}
+
+func (p *printer) printEOL(inline []Token) {
+	for _, t := range inline {
+		p.writer.WriteString(" ")
+		p.writer.WriteString(formatComment(t))
+	}
+	p.writer.WriteString("\n")
+}
+
+func (p *printer) printOptions(list []Node) error {
+	var width int
+	for _, n := range list {
+		key, err := formatKey(n.(*option).Ident)
+		if err != nil {
+			return err
+		}
+		if z := len(key); z > width {
+			width = z
+		}
+	}
+	for _, n := range list {
+		if err := p.printOption(n.(*option), width); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *printer) printOption(opt *option, width int) error {
+	key, err := formatKey(opt.Ident)
+	if err != nil {
+		return err
+	}
+	before, inline, after := splitComment(opt)
+	p.printComments(before)
+	p.printIndent()
+	p.writer.WriteString(key)
+	p.writer.WriteString(strings.Repeat(" ", width-len(key)))
+	if opt.Value == nil {
+		p.writer.WriteString(" =")
+	} else {
+		p.writer.WriteString(" = ")
+		str, err := p.formatValue(opt.Value, width+3)
+		if err != nil {
+			return fmt.Errorf("%s: %w", opt.Ident, err)
+		}
+		p.writer.WriteString(str)
+	}
+	p.printEOL(inline)
+	p.printComments(after)
+	return nil
+}
+
+func (p *printer) printIndent() {
+	p.writer.WriteString(p.prefix())
+}
+
+func (p *printer) prefix() string {
+	return p.base + strings.Repeat(indent, p.level)
+}
+
+func (p *printer) formatValue(n Node, offset int) (string, error) {
+	switch n := n.(type) {
+	case *literal:
+		return formatLiteral(n)
+	case *variable:
+		return formatVariable(n, false), nil
+	case *template:
+		return formatTemplate(n)
+	case *slice:
+		return p.formatSlice(n, offset)
+	case *call:
+		return p.formatCall(n)
+	case *array:
+		return p.formatArray(n, offset)
+	case *unary:
+		str, err := p.formatOperand(n.Right, powPrefix, false)
+		return n.Op.Literal + str, err
+	case *binary:
+		pow := bindings[n.Op.Type]
+		left, err := p.formatOperand(n.Left, pow, false)
+		if err != nil {
+			return "", err
+		}
+		right, err := p.formatOperand(n.Right, pow, true)
+		return group(fmt.Sprintf("%s %s %s", left, n.Op.Literal, right), n.group), err
+	case *ternary:
+		cond, err := p.formatOperand(n.Cond, powTernary, true)
+		if err != nil {
+			return "", err
+		}
+		csq, err := p.formatValue(n.Csq, 0)
+		if err != nil {
+			return "", err
+		}
+		alt, err := p.formatOperand(n.Alt, powTernary, false)
+		return group(fmt.Sprintf("%s ? %s : %s", cond, csq, alt), n.group), err
+	default:
+		return "", fmt.Errorf("can not print %T", n)
+	}
+}
+
+func (p *printer) formatOperand(n Node, pow int, right bool) (string, error) {
+	str, err := p.formatValue(n, 0)
+	if err != nil {
+		return "", err
+	}
+	if isGroup(n) {
+		return str, nil
+	}
+	var curr int
+	switch n := n.(type) {
+	case *unary:
+		curr = powPrefix
+	case *binary:
+		curr = bindings[n.Op.Type]
+	case *ternary:
+		curr = powTernary
+	default:
+		return str, nil
+	}
+	if curr < pow || right && curr == pow {
+		str = "(" + str + ")"
+	}
+	return str, nil
+}
+
+func group(str string, ok bool) string {
+	if ok {
+		str = "(" + str + ")"
+	}
+	return str
+}
+
+func (p *printer) formatArray(arr *array, offset int) (string, error) {
+	var list []string
+	for _, n := range arr.Nodes {
+		str, err := p.formatValue(n, 0)
+		if err != nil {
+			return "", err
+		}
+		list = append(list, str)
+	}
+	str := "[" + strings.Join(list, ", ") + "]"
+	if !strings.Contains(str, "\n") && !hasComments(arr) && len(p.prefix())+offset+len(str) <= maxWidth {
+		return str, nil
+	}
+	var (
+		buf strings.Builder
+		pfx = p.prefix()
+	)
+	p.level++
+	defer func() { p.level-- }()
+
+	buf.WriteString("[\n")
+	if c, ok := arr.Comment.(*note); ok && len(arr.Nodes) == 0 {
+		p.writeComments(&buf, c.Tokens)
+	}
+	for _, n := range arr.Nodes {
+		str, err := p.formatValue(n, 0)
+		if err != nil {
+			return "", err
+		}
+		before, inline, after := splitComment(n)
+		p.writeComments(&buf, before)
+		buf.WriteString(p.prefix())
+		buf.WriteString(str)
+		buf.WriteString(",")
+		for _, t := range inline {
+			buf.WriteString(" ")
+			buf.WriteString(formatComment(t))
+		}
+		buf.WriteString("\n")
+		p.writeComments(&buf, after)
+	}
+	buf.WriteString(pfx)
+	buf.WriteString("]")
+	return buf.String(), nil
+}
+
+func (p *printer) writeComments(buf *strings.Builder, list []Token) {
+	for _, t := range list {
+		buf.WriteString(p.prefix())
+		buf.WriteString(formatComment(t))
+		buf.WriteString("\n")
+	}
+}
+
+func (p *printer) formatSlice(slc *slice, offset int) (string, error) {
+	str, err := p.formatValue(slc.Node, offset)
+	if err != nil {
+		return "", err
+	}
+	if slc.IsIndex() {
+		return fmt.Sprintf("%s[%d]", str, slc.from.index), nil
+	}
+	var buf strings.Builder
+	buf.WriteString(str)
+	buf.WriteString("[")
+	if slc.from.index != 0 {
+		fmt.Fprintf(&buf, "%d", slc.from.index)
+	}
+	buf.WriteString(":")
+	if slc.to.set {
+		fmt.Fprintf(&buf, "%d", slc.to.index)
+	}
+	buf.WriteString("]")
+	return buf.String(), nil
+}
+
+func (p *printer) formatCall(c *call) (string, error) {
+	var list []string
+	for _, n := range c.Args {
+		str, err := p.formatValue(n, 0)
+		if err != nil {
+			return "", err
+		}
+		list = append(list, str)
+	}
+	for _, k := range c.Keys() {
+		str, err := p.formatValue(c.Kwargs[k], 0)
+		if err != nil {
+			return "", err
+		}
+		list = append(list, k+"="+str)
+	}
+	return fmt.Sprintf("%s(%s)", c.Ident, strings.Join(list, ", ")), nil
+}
+
+func formatComment(tok Token) string {
+	str := strings.TrimRight(tok.Literal, " \t")
+	if strings.Contains(str, "\n") {
+		return fmt.Sprintf("/*\n%s\n*/", strings.TrimSpace(str))
+	}
+	if str == "" || isBlank(rune(str[0])) {
+		return "#" + str
+	}
+	return "# " + str
+}
+
+func formatTemplate(t *template) (string, error) {
+	var buf strings.Builder
+	buf.WriteString("`")
+	for _, n := range t.Nodes {
+		switch n := n.(type) {
+		case *literal:
+			buf.WriteString(n.Token.Literal)
+		case *variable:
+			buf.WriteString(formatVariable(n, true))
+		default:
+			return "", fmt.Errorf("can not print %T in template", n)
+		}
+	}
+	buf.WriteString("`")
+	return buf.String(), nil
+}
+
+func formatVariable(v *variable, placeholder bool) string {
+	prefix := "$"
+	if !v.IsLocal() {
+		prefix = "@"
+	}
+	if placeholder {
+		return fmt.Sprintf("%s{%s}", prefix, v.Name())
+	}
+	return prefix + v.Name()
+}
+
+func formatKey(ident string) (string, error) {
+	if isIdentString(ident) {
+		return ident, nil
+	}
+	if strings.ContainsRune(ident, dquote) && strings.ContainsRune(ident, squote) {
+		return "", fmt.Errorf("%q: key with both quotes can not be written", ident)
+	}
+	return formatString(ident)
+}
+
+func formatLiteral(i *literal) (string, error) {
+	if i.Token.Source != "" {
+		return i.Token.Source + i.Mul.Literal, nil
+	}
+	switch str := i.Token.Literal; i.Token.Type {
+	case Heredoc:
+		if str == "" || str != strings.TrimSpace(str) {
+			return formatString(str)
+		}
+		return formatHeredoc(str), nil
+	case String:
+		return formatString(str)
+	default:
+		return str + i.Mul.Literal, nil
+	}
+}
+
+func formatString(str string) (string, error) {
+	heredoc := str != "" && str == strings.TrimSpace(str)
+	if heredoc && strings.ContainsAny(str, "\r\n") {
+		return formatHeredoc(str), nil
+	}
+	if !strings.ContainsRune(str, dquote) {
+		return fmt.Sprintf("\"%s\"", str), nil
+	}
+	if !strings.ContainsRune(str, squote) {
+		return fmt.Sprintf("'%s'", str), nil
+	}
+	if heredoc {
+		return formatHeredoc(str), nil
+	}
+	var list []string
+	for i, part := range strings.Split(str, "\"") {
+		if i > 0 {
+			list = append(list, "'\"'")
+		}
+		if part != "" {
+			list = append(list, fmt.Sprintf("\"%s\"", part))
+		}
+	}
+	return strings.Join(list, " + "), nil
+}
+
+func formatHeredoc(str string) string {
+	label := "EOF"
+	for hasLine(str, label) {
+		label += "F"
+	}
+	return fmt.Sprintf("<<%s\n%s\n%s", label, str, label)
+}
+
+func hasLine(str, line string) bool {
+	for _, s := range strings.Split(str, "\n") {
+		if s == line {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdentString(str string) bool {
+	switch str {
+	case "", "true", "false", "yes", "no", "on", "off":
+		return false
+	}
+	for i, r := range str {
+		if i == 0 && !isLetter(r) {
+			return false
+		}
+		if !isIdent(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isOption(n Node) bool {
+	_, ok := n.(*option)
+	return ok
+}
+
+func hasComments(arr *array) bool {
+	if arr.Comment != nil {
+		return true
+	}
+	for _, n := range arr.Nodes {
+		if getComment(n) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func splitComment(n Node) ([]Token, []Token, []Token) {
+	c := getComment(n)
+	if c == nil {
+		return nil, nil, nil
+	}
+	var (
+		pos, end              = n.Pos(), n.End()
+		before, inline, after []Token
+	)
+	for _, t := range c.Tokens {
+		switch {
+		case t.Line < pos.Line:
+			before = append(before, t)
+		case t.Line <= end.Line && len(inline) == 0:
+			inline = append(inline, t)
+		default:
+			after = append(after, t)
+		}
+	}
+	return before, inline, after
+}
+
+func hasBlank(prev, next Node) bool {
+	_, end := nodeSpan(prev)
+	pos, _ := nodeSpan(next)
+	if end.Line == 0 || pos.Line == 0 {
+		return false
+	}
+	return pos.Line-end.Line > 1
+}
+
+func nodeSpan(n Node) (Position, Position) {
+	pos, end := n.Pos(), n.End()
+	if c := getComment(n); c != nil && pos.Line > 0 {
+		if p := c.Pos(); p.Line < pos.Line {
+			pos = p
+		}
+		if e := c.End(); e.Line > end.Line {
+			end = e
+		}
+	}
+	return pos, end
+}
@@ -66,6 +66,7 @@ type Scanner struct {
 	seen   int
 
 	template bool
+	last     rune
 }
 
 func Scan(r io.Reader) (*Scanner, error) {
@@ -83,10 +84,22 @@ func Scan(r io.Reader) (*Scanner, error) {
 }
 
 func (s *Scanner) Scan() Token {
+	tok := s.scan()
+	if tok.Type != Comment {
+		s.last = tok.Type
+	}
+	if tok.isNumber() || tok.Type == Heredoc {
+		tok.Source = string(s.input[tok.Offset:s.curr])
+	}
+	return tok
+}
+
+func (s *Scanner) scan() Token {
 	var tok Token
 	tok.Position = Position{
-		Line: s.line,
-		Col:  s.column,
+		Line:   s.line,
+		Col:    s.column,
+		Offset: s.curr,
 	}
 	s.reset()
 
@@ -97,7 +110,8 @@ func (s *Scanner) Scan() Token {
 
 	s.skipBlank()
 	tok.Position.Line = s.line
-	tok.Position.Line = s.column
+	tok.Position.Col = s.column
+	tok.Position.Offset = s.curr
 	if s.char == 0 || s.char == utf8.RuneError {
 		tok.Type = EOF
 		return tok
@@ -114,8 +128,10 @@ func (s *Scanner) Scan() Token {
 		s.scanIdent(&tok)
 	case isVariable(s.char):
 		s.scanVariable(&tok)
-	case isDigit(s.char) || isSign(s.char):
+	case isDigit(s.char) || isSign(s.char) && isDigit(s.peek()) && !s.afterValue():
 		s.scanNumber(&tok)
+	case isOperator(s.char):
+		s.scanOperator(&tok)
 	case isBacktick(s.char):
 		s.scanTemplate(&tok)
 	case isQuote(s.char):
@@ -125,6 +141,8 @@ func (s *Scanner) Scan() Token {
 	case isMacro(s.char):
 		tok.Type = Macro
 		s.read()
+	case isAssign(s.char) && s.peek() == equal:
+		s.scanOperator(&tok)
 	case isAssign(s.char):
 		tok.Type = Assign
 		s.read()
@@ -138,8 +156,78 @@ func (s *Scanner) Scan() Token {
 	return tok
 }
 
+func (s *Scanner) scanOperator(tok *Token) {
+	var (
+		char = s.char
+		peek = s.peek()
+	)
+	switch char {
+	case plus:
+		tok.Type = Add
+	case minus:
+		tok.Type = Sub
+	case star:
+		tok.Type = Mul
+	case slash:
+		tok.Type = Div
+	case percent:
+		tok.Type = Mod
+	case question:
+		tok.Type = Ternary
+	case equal:
+		tok.Type = Eq
+	case bang:
+		tok.Type = Not
+		if peek == equal {
+			tok.Type = Ne
+		}
+	case langle:
+		tok.Type = Lt
+		if peek == equal {
+			tok.Type = Le
+		}
+	case rangle:
+		tok.Type = Gt
+		if peek == equal {
+			tok.Type = Ge
+		}
+	case ampersand:
+		tok.Type = And
+		if peek != ampersand {
+			tok.Type = Invalid
+		}
+	case pipe:
+		tok.Type = Or
+		if peek != pipe {
+			tok.Type = Invalid
+		}
+	}
+	s.str.WriteRune(s.char)
+	s.read()
+	switch tok.Type {
+	case Eq, Ne, Le, Ge, And, Or:
+		s.str.WriteRune(s.char)
+		s.read()
+	default:
+	}
+	tok.Literal = s.str.String()
+}
+
+func (s *Scanner) afterValue() bool {
+	switch s.last {
+	case Integer, Float, String, Boolean, Heredoc, Ident, LocalVar, EnvVar, EndArr, EndGrp:
+		return true
+	case Template:
+		return !s.template
+	default:
+		return false
+	}
+}
+
 func (s *Scanner) scanTemplate(tok *Token) {
 	switch {
+	case s.done():
+		tok.Type = EOF
 	case isBacktick(s.char):
 		tok.Type = Template
 		s.template = !s.template
@@ -266,11 +354,7 @@ func (s *Scanner) scanIdent(tok *Token) {
 func (s *Scanner) scanString(tok *Token) {
 	quote := s.char
 	s.read()
-	for !s.done() {
-		if s.char == quote {
-			s.read()
-			break
-		}
+	for !s.done() && s.char != quote {
 		s.str.WriteRune(s.char)
 		s.read()
 	}
@@ -278,7 +362,9 @@ func (s *Scanner) scanString(tok *Token) {
 	tok.Type = String
 	if s.done() {
 		tok.Type = Invalid
+		return
 	}
+	s.read()
 }
 
 func (s *Scanner) scanNumber(tok *Token) {
@@ -443,10 +529,12 @@ func (s *Scanner) scanDelimiter(tok *Token) {
 func (s *Scanner) scanComment(tok *Token, multi bool) {
 	if multi {
 		s.scanCommentMultiline(tok)
+		s.skipBlank()
+		s.skipNL()
+		return
 	}
 	s.read()
-	s.skipBlank()
-	for !isNL(s.char) {
+	for !isNL(s.char) && !s.done() {
 		s.str.WriteRune(s.char)
 		s.read()
 	}
@@ -593,6 +681,15 @@ func isDelim(b rune) bool {
 		b == colon
 }
 
+func isOperator(b rune) bool {
+	switch b {
+	case plus, minus, star, slash, percent, question, bang, langle, rangle, ampersand, pipe:
+		return true
+	default:
+		return false
+	}
+}
+
 func isAssign(b rune) bool {
 	return b == equal
 }
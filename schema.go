@@ -0,0 +1,471 @@
+package fig
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	kindAny     = "any"
+	kindString  = "string"
+	kindInteger = "integer"
+	kindFloat   = "float"
+	kindNumber  = "number"
+	kindBoolean = "boolean"
+	kindArray   = "array"
+	kindObject  = "object"
+)
+
+var kinds = map[string]struct{}{
+	kindAny:     {},
+	kindString:  {},
+	kindInteger: {},
+	kindFloat:   {},
+	kindNumber:  {},
+	kindBoolean: {},
+	kindArray:   {},
+	kindObject:  {},
+}
+
+type Violation struct {
+	Path    string
+	Message string
+	Position
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("%s: %s: %s", v.Position, v.Path, v.Message)
+}
+
+type Violations []Violation
+
+func (v Violations) Error() string {
+	var list []string
+	for _, e := range v {
+		list = append(list, e.Error())
+	}
+	return strings.Join(list, "\n")
+}
+
+type Schema struct {
+	rules []*rule
+}
+
+func LoadSchema(r io.Reader) (*Schema, error) {
+	n, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := n.(*object)
+	if !ok {
+		return nil, fmt.Errorf("root node is not an object")
+	}
+	rules, err := compileRules(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{rules: rules}, nil
+}
+
+func (s *Schema) Validate(n Node) error {
+	return s.validate(n, EmptyEnv())
+}
+
+func (s *Schema) validate(n Node, env *Env) error {
+	obj, ok := n.(*object)
+	if !ok {
+		return fmt.Errorf("root node is not an object")
+	}
+	v := validator{
+		env: env,
+	}
+	v.validateObject(obj, &rule{Fields: s.rules}, "")
+	if len(v.list) == 0 {
+		return nil
+	}
+	return v.list
+}
+
+type bound struct {
+	value float64
+	set   bool
+}
+
+type rule struct {
+	Name     string
+	Kind     string
+	Required bool
+	Repeated bool
+	Strict   bool
+	Enum     []interface{}
+	Pattern  *regexp.Regexp
+	Items    *rule
+	Values   *rule
+	Fields   []*rule
+
+	min    bound
+	max    bound
+	minlen bound
+	maxlen bound
+}
+
+func compileRules(obj *object) ([]*rule, error) {
+	var rules []*rule
+	for i, n := range obj.Nodes {
+		var (
+			r   *rule
+			err error
+		)
+		switch n := n.(type) {
+		case *option:
+			r, err = compileShort(n)
+		case *object:
+			r, err = compileRule(n)
+		case *array:
+			err = fmt.Errorf("%s: %s: rule defined multiple times", n.Pos(), obj.Revex[i])
+		case *note:
+			continue
+		default:
+			err = fmt.Errorf("%s: %s: invalid schema rule", n.Pos(), obj.Revex[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func compileShort(opt *option) (*rule, error) {
+	kind, err := opt.GetString()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: type expected", opt.Pos(), opt.Ident)
+	}
+	if _, ok := kinds[kind]; !ok {
+		return nil, fmt.Errorf("%s: %s: unknown type %s", opt.Pos(), opt.Ident, kind)
+	}
+	r := rule{
+		Name: opt.Ident,
+		Kind: kind,
+	}
+	return &r, nil
+}
+
+func compileRule(obj *object) (*rule, error) {
+	r := rule{
+		Name: obj.Name,
+		Kind: kindAny,
+	}
+	for _, n := range obj.Nodes {
+		var err error
+		switch n := n.(type) {
+		case *option:
+			err = r.setOption(n)
+		case *object:
+			switch n.Name {
+			case "items":
+				r.Items, err = compileRule(n)
+			case "values":
+				r.Values, err = compileRule(n)
+			case "fields":
+				r.Fields, err = compileRules(n)
+			default:
+				err = fmt.Errorf("%s: %s: unknown schema property", n.Pos(), n.Name)
+			}
+		case *note:
+		default:
+			err = fmt.Errorf("%s: %s: invalid schema property", n.Pos(), obj.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Values != nil || len(r.Fields) > 0 {
+		if r.Kind == kindAny {
+			r.Kind = kindObject
+		}
+	}
+	if r.Items != nil && r.Kind == kindAny {
+		r.Kind = kindArray
+	}
+	return &r, nil
+}
+
+func (r *rule) setOption(opt *option) error {
+	var err error
+	switch opt.Ident {
+	case "type":
+		var kind string
+		if kind, err = opt.GetString(); err == nil {
+			if _, ok := kinds[kind]; !ok {
+				err = fmt.Errorf("unknown type %s", kind)
+			}
+			r.Kind = kind
+		}
+	case "required":
+		r.Required, err = opt.GetBool()
+	case "repeated":
+		r.Repeated, err = opt.GetBool()
+	case "strict":
+		r.Strict, err = opt.GetBool()
+	case "min":
+		r.min.value, err = opt.GetFloat()
+		r.min.set = true
+	case "max":
+		r.max.value, err = opt.GetFloat()
+		r.max.set = true
+	case "minlen":
+		r.minlen.value, err = opt.GetFloat()
+		r.minlen.set = true
+	case "maxlen":
+		r.maxlen.value, err = opt.GetFloat()
+		r.maxlen.set = true
+	case "pattern":
+		var str string
+		if str, err = opt.GetString(); err == nil {
+			r.Pattern, err = regexp.Compile(str)
+		}
+	case "enum":
+		r.Enum, err = compileEnum(opt.Value)
+	case "items":
+		r.Items, err = compileShort(opt)
+	case "values":
+		r.Values, err = compileShort(opt)
+	default:
+		err = fmt.Errorf("unknown schema property")
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s.%s: %w", opt.Pos(), r.Name, opt.Ident, err)
+	}
+	return nil
+}
+
+func compileEnum(n Node) ([]interface{}, error) {
+	var list []Node
+	switch n := n.(type) {
+	case *literal:
+		list = append(list, n)
+	case *array:
+		list = n.Nodes
+	default:
+		return nil, fmt.Errorf("literal or array of literals expected")
+	}
+	var values []interface{}
+	for _, n := range list {
+		lit, ok := n.(*literal)
+		if !ok {
+			return nil, fmt.Errorf("literal expected")
+		}
+		v, err := lit.Get()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+type validator struct {
+	env  *Env
+	list Violations
+}
+
+func (v *validator) report(path string, pos Position, msg string, args ...interface{}) {
+	e := Violation{
+		Path:     path,
+		Message:  fmt.Sprintf(msg, args...),
+		Position: pos,
+	}
+	v.list = append(v.list, e)
+}
+
+func (v *validator) validateObject(obj *object, r *rule, path string) {
+	known := make(map[string]struct{})
+	for _, f := range r.Fields {
+		known[f.Name] = struct{}{}
+		n, ok := obj.take(f.Name)
+		if !ok {
+			if f.Required {
+				v.report(joinPath(path, f.Name), obj.pos, "required field missing")
+			}
+			continue
+		}
+		v.validateNode(n, f, joinPath(path, f.Name), obj)
+	}
+	for i, n := range obj.Nodes {
+		if _, ok := n.(*note); ok {
+			continue
+		}
+		name := obj.Revex[i]
+		if _, ok := known[name]; ok {
+			continue
+		}
+		switch {
+		case r.Values != nil:
+			v.validateNode(n, r.Values, joinPath(path, name), obj)
+		case r.Strict:
+			v.report(joinPath(path, name), n.Pos(), "unknown field")
+		}
+	}
+}
+
+func (v *validator) validateNode(n Node, r *rule, path string, scope *object) {
+	var blocks []Node
+	switch n := n.(type) {
+	case *object:
+		blocks = append(blocks, n)
+	case *array:
+		for _, n := range n.Nodes {
+			if n.Type() != TypeObject {
+				break
+			}
+			blocks = append(blocks, n)
+		}
+	}
+	if !r.Repeated {
+		if len(blocks) > 1 {
+			v.report(path, blocks[1].Pos(), "block can not be repeated")
+			return
+		}
+		v.validateValue(n, r, path, scope)
+		return
+	}
+	if len(blocks) == 0 {
+		v.report(path, n.Pos(), "repeated block expected")
+		return
+	}
+	v.checkLength(path, n.Pos(), len(blocks), r)
+	for i, b := range blocks {
+		v.validateValue(b, r, fmt.Sprintf("%s[%d]", path, i), scope)
+	}
+}
+
+func (v *validator) validateValue(n Node, r *rule, path string, scope *object) {
+	switch n := n.(type) {
+	case *object:
+		if r.Kind != kindAny && r.Kind != kindObject {
+			v.report(path, n.Pos(), "%s expected! got %s", r.Kind, kindObject)
+			return
+		}
+		v.validateObject(n, r, path)
+	case *option:
+		if n.Value == nil {
+			return
+		}
+		val, err := eval(n.Value, callMacro(scope, v.env))
+		if err != nil {
+			v.report(path, n.Pos(), "%s", err)
+			return
+		}
+		v.checkValue(val, n.Value, n.Pos(), r, path)
+	default:
+		v.report(path, n.Pos(), "unexpected node")
+	}
+}
+
+func (v *validator) checkValue(val interface{}, n Node, pos Position, r *rule, path string) {
+	kind := kindOf(val)
+	if !acceptKind(r.Kind, kind) {
+		v.report(path, pos, "%s expected! got %s", r.Kind, kind)
+		return
+	}
+	if len(r.Enum) > 0 {
+		var found bool
+		for _, e := range r.Enum {
+			if found = isEqual(e, val); found {
+				break
+			}
+		}
+		if !found {
+			v.report(path, pos, "%v is not one of the allowed values", val)
+		}
+	}
+	if f, ok := toFloat(val); ok {
+		if r.min.set && f < r.min.value {
+			v.report(path, pos, "%v is lower than %v", val, r.min.value)
+		}
+		if r.max.set && f > r.max.value {
+			v.report(path, pos, "%v is greater than %v", val, r.max.value)
+		}
+	}
+	switch val := val.(type) {
+	case string:
+		v.checkLength(path, pos, utf8.RuneCountInString(val), r)
+		if r.Pattern != nil && !r.Pattern.MatchString(val) {
+			v.report(path, pos, "%q does not match %s", val, r.Pattern)
+		}
+		return
+	}
+	list := reflect.ValueOf(val)
+	if !isArray(list) {
+		return
+	}
+	v.checkLength(path, pos, list.Len(), r)
+	if r.Items == nil {
+		return
+	}
+	arr, _ := n.(*array)
+	for i := 0; i < list.Len(); i++ {
+		at := pos
+		if arr != nil && i < len(arr.Nodes) {
+			at = arr.Nodes[i].Pos()
+		}
+		v.checkValue(list.Index(i).Interface(), nil, at, r.Items, fmt.Sprintf("%s[%d]", path, i))
+	}
+}
+
+func (v *validator) checkLength(path string, pos Position, size int, r *rule) {
+	if r.minlen.set && float64(size) < r.minlen.value {
+		v.report(path, pos, "length %d is lower than %v", size, r.minlen.value)
+	}
+	if r.maxlen.set && float64(size) > r.maxlen.value {
+		v.report(path, pos, "length %d is greater than %v", size, r.maxlen.value)
+	}
+}
+
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return kindString
+	case bool:
+		return kindBoolean
+	}
+	if _, ok := toInt(v); ok {
+		return kindInteger
+	}
+	if _, ok := toFloat(v); ok {
+		return kindFloat
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Array:
+		return kindArray
+	case reflect.Map, reflect.Struct:
+		return kindObject
+	default:
+		return kindAny
+	}
+}
+
+func acceptKind(want, got string) bool {
+	switch want {
+	case kindAny, got:
+		return true
+	case kindNumber:
+		return got == kindInteger || got == kindFloat
+	case kindFloat:
+		return got == kindInteger
+	default:
+		return false
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
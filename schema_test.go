@@ -0,0 +1,113 @@
+package fig_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/midbel/fig"
+)
+
+func ExampleSchema_Validate() {
+	const schema = `
+name {
+  type     = string
+  required = true
+  pattern  = "^[a-z]+$"
+}
+mode {
+  type = string
+  enum = [dev, prod]
+}
+server {
+  repeated = true
+  fields {
+    addr {
+      type     = string
+      required = true
+    }
+    port {
+      type = integer
+      min  = 1
+      max  = 65535
+    }
+  }
+}
+`
+	const demo = `
+name = "demo"
+mode = test
+server {
+  addr = "192.168.67.181"
+  port = 80
+}
+server {
+  port = 80000
+}
+`
+	s, err := fig.LoadSchema(strings.NewReader(schema))
+	if err != nil {
+		fmt.Printf("fail to load schema: %s\n", err)
+		return
+	}
+	n, err := fig.Parse(strings.NewReader(demo))
+	if err != nil {
+		fmt.Printf("fail to parse document: %s\n", err)
+		return
+	}
+	fmt.Println(s.Validate(n))
+	// Output:
+	// 3:1: mode: test is not one of the allowed values
+	// 8:1: server[1].addr: required field missing
+	// 9:3: server[1].port: 80000 is greater than 65535
+}
+
+func ExampleSchema_Validate_comments() {
+	const schema = `
+# the name of the application
+name = string
+
+# floating comment
+
+port {
+  # a valid port number
+  type = integer
+  max  = 65535 # upper bound
+}
+
+server {
+  strict = true
+  fields {
+    addr = string
+    host = string
+  }
+}
+`
+	const demo = `
+# demo application
+
+name = demo # the name
+
+port = 80000
+
+server {
+  # floating comment
+
+  addr = "192.168.67.181" # address
+  host = $hostname
+}
+`
+	s, err := fig.LoadSchema(strings.NewReader(schema))
+	if err != nil {
+		fmt.Printf("fail to load schema: %s\n", err)
+		return
+	}
+	n, err := fig.Parse(strings.NewReader(demo))
+	if err != nil {
+		fmt.Printf("fail to parse document: %s\n", err)
+		return
+	}
+	fmt.Println(s.Validate(n))
+	// Output:
+	// 6:1: port: 80000 is greater than 65535
+	// 12:3: server.host: hostname: undefined option
+}
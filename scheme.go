@@ -0,0 +1,221 @@
+package fig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SchemeHandler func(*url.URL) (io.ReadCloser, error)
+
+var schemes = struct {
+	sync.RWMutex
+	handlers map[string]SchemeHandler
+}{
+	handlers: map[string]SchemeHandler{
+		"http":  readRemote,
+		"https": readRemote,
+	},
+}
+
+func RegisterScheme(scheme string, handler SchemeHandler) {
+	schemes.Lock()
+	defer schemes.Unlock()
+
+	scheme = strings.ToLower(scheme)
+	if handler == nil {
+		delete(schemes.handlers, scheme)
+		return
+	}
+	schemes.handlers[scheme] = handler
+}
+
+func (p *Parser) RegisterScheme(scheme string, handler SchemeHandler) {
+	if p.schemes == nil {
+		p.schemes = make(map[string]SchemeHandler)
+	}
+	p.schemes[strings.ToLower(scheme)] = handler
+}
+
+func (p *Parser) schemeHandler(scheme string) (SchemeHandler, bool) {
+	if h, ok := p.schemes[scheme]; ok {
+		return h, h != nil
+	}
+	schemes.RLock()
+	defer schemes.RUnlock()
+
+	h, ok := schemes.handlers[scheme]
+	return h, ok
+}
+
+func (p *Parser) openURL(file string) (io.ReadCloser, error) {
+	u, err := url.Parse(file)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := p.schemeHandler(u.Scheme)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported scheme (%s)", u.Scheme, file)
+	}
+	return h(u)
+}
+
+const defaultTimeout = 30 * time.Second
+
+var defaultClient = &http.Client{
+	Timeout: defaultTimeout,
+}
+
+type Remote struct {
+	Client *http.Client
+	Header http.Header
+	Cache  string
+}
+
+func readRemote(u *url.URL) (io.ReadCloser, error) {
+	var r Remote
+	return r.Open(u)
+}
+
+func (r Remote) Open(u *url.URL) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range r.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	file := r.cacheFile(u)
+	if meta, err := readMeta(file); err == nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+	res, err := r.client().Do(req)
+	if err != nil {
+		if rc, err1 := openCache(file); err1 == nil {
+			return rc, nil
+		}
+		return nil, err
+	}
+	switch code := res.StatusCode; {
+	case code == http.StatusNotModified:
+		res.Body.Close()
+		return openCache(file)
+	case code >= http.StatusInternalServerError:
+		res.Body.Close()
+		if rc, err := openCache(file); err == nil {
+			return rc, nil
+		}
+		return nil, fmt.Errorf("%s: %s", u, res.Status)
+	case code >= http.StatusBadRequest:
+		res.Body.Close()
+		return nil, fmt.Errorf("%s: %s", u, res.Status)
+	}
+	if file == "" {
+		return res.Body, nil
+	}
+	defer res.Body.Close()
+
+	buf, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	meta := cacheMeta{
+		URL:          u.String(),
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+	}
+	if err := writeCache(file, buf, meta); err != nil {
+		return nil, fmt.Errorf("%s: fail to write cache: %w", u, err)
+	}
+	return io.NopCloser(bytes.NewReader(buf)), nil
+}
+
+func (r Remote) client() *http.Client {
+	if r.Client == nil {
+		return defaultClient
+	}
+	return r.Client
+}
+
+func (r Remote) cacheFile(u *url.URL) string {
+	if r.Cache == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(u.String()))
+	return filepath.Join(r.Cache, hex.EncodeToString(sum[:]))
+}
+
+type cacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last-modified,omitempty"`
+}
+
+func readMeta(file string) (cacheMeta, error) {
+	var meta cacheMeta
+	if file == "" {
+		return meta, fmt.Errorf("no cache")
+	}
+	if _, err := os.Stat(file); err != nil {
+		return meta, err
+	}
+	buf, err := os.ReadFile(file + ".json")
+	if err == nil {
+		err = json.Unmarshal(buf, &meta)
+	}
+	return meta, err
+}
+
+func openCache(file string) (io.ReadCloser, error) {
+	if file == "" {
+		return nil, fmt.Errorf("no cache")
+	}
+	return os.Open(file)
+}
+
+func writeCache(file string, buf []byte, meta cacheMeta) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	if err := writeAtomic(file, buf); err != nil {
+		return err
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(file+".json", data)
+}
+
+func writeAtomic(file string, buf []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(file), ".fig-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
@@ -0,0 +1,130 @@
+package fig_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/midbel/fig"
+)
+
+func ExampleRegisterScheme() {
+	store := map[string]string{
+		"configs/app.fig": "name = app\n.include(\"db.fig\", name=db, method=append, fatal=true)\n",
+		"configs/db.fig":  "addr = \"10.0.0.1\"\n",
+	}
+	fig.RegisterScheme("kv", func(u *url.URL) (io.ReadCloser, error) {
+		str, ok := store[u.Host+u.Path]
+		if !ok {
+			return nil, fmt.Errorf("%s: key not found", u)
+		}
+		return io.NopCloser(strings.NewReader(str)), nil
+	})
+	defer fig.RegisterScheme("kv", nil)
+
+	const demo = `.include("kv://configs/app.fig", name=app, method=append, fatal=true)`
+
+	var cfg struct {
+		App struct {
+			Name string
+			DB   struct {
+				Addr string
+			} `fig:"db"`
+		}
+	}
+	if err := fig.NewDecoder(strings.NewReader(demo)).Decode(&cfg); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(cfg.App.Name, cfg.App.DB.Addr)
+
+	const missing = `.include("vault://secrets/app.fig", fatal=true)`
+	_, err := fig.Parse(strings.NewReader(missing))
+	fmt.Println(err)
+	// Output:
+	// app 10.0.0.1
+	// 1:2: vault: unsupported scheme (vault://secrets/app.fig)
+}
+
+func TestRemote(t *testing.T) {
+	const (
+		content = "addr = \"10.0.0.1\"\n"
+		etag    = `"v1"`
+		token   = "Bearer secret"
+	)
+	var hits, cached int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("Authorization") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			cached++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, content)
+	}))
+
+	sum := sha256.Sum256([]byte(content))
+	remote := fig.Remote{
+		Client: srv.Client(),
+		Header: http.Header{"Authorization": []string{token}},
+		Cache:  t.TempDir(),
+	}
+	parse := func(sum string) error {
+		doc := fmt.Sprintf(".include(%q, name=db, fatal=true, sha256=%q)", srv.URL+"/db.fig", sum)
+		p, err := fig.NewParser(strings.NewReader(doc))
+		if err != nil {
+			return err
+		}
+		p.RegisterScheme("http", remote.Open)
+		_, err = p.Parse()
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := parse(hex.EncodeToString(sum[:])); err != nil {
+			t.Fatalf("fail to include remote file: %s", err)
+		}
+	}
+	if hits != 2 || cached != 1 {
+		t.Errorf("conditional request expected! got %d hits and %d not modified", hits, cached)
+	}
+	if err := parse(strings.Repeat("0", 64)); !errors.Is(err, fig.ErrIntegrity) {
+		t.Errorf("integrity error expected! got %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(remote.Cache, "*.json"))
+	for _, f := range files {
+		os.Remove(strings.TrimSuffix(f, ".json"))
+	}
+	if err := parse(hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("fail to include remote file without cached content: %s", err)
+	}
+	if hits != 4 || cached != 2 {
+		t.Errorf("unconditional request expected! got %d hits and %d not modified", hits, cached)
+	}
+
+	cache := remote.Cache
+	remote.Cache = filepath.Join(files[0], "cache")
+	if err := parse(hex.EncodeToString(sum[:])); err == nil {
+		t.Errorf("cache error expected when the cache can not be written")
+	}
+	remote.Cache = cache
+
+	srv.Close()
+	if err := parse(hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("fail to include remote file from cache: %s", err)
+	}
+}
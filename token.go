@@ -5,8 +5,9 @@ import (
 )
 
 type Position struct {
-	Line int
-	Col  int
+	Line   int
+	Col    int
+	Offset int
 }
 
 func (p Position) String() string {
@@ -35,6 +36,21 @@ const (
 	Comma
 	Slice
 	Assign
+	Add
+	Sub
+	Mul
+	Div
+	Mod
+	Eq
+	Ne
+	Lt
+	Le
+	Gt
+	Ge
+	And
+	Or
+	Not
+	Ternary
 	EOL
 	Invalid
 )
@@ -63,10 +79,26 @@ var types = map[rune]string{
 	LocalVar: "local-var",
 	EnvVar:   "env-var",
 	Slice:    "slice",
+	Add:      "add",
+	Sub:      "sub",
+	Mul:      "mul",
+	Div:      "div",
+	Mod:      "mod",
+	Eq:       "eq",
+	Ne:       "ne",
+	Lt:       "lt",
+	Le:       "le",
+	Gt:       "gt",
+	Ge:       "ge",
+	And:      "and",
+	Or:       "or",
+	Not:      "not",
+	Ternary:  "ternary",
 }
 
 type Token struct {
 	Literal     string
+	Source      string
 	Type        rune
 	Interpolate bool
 	Position
@@ -0,0 +1,94 @@
+package fig
+
+type Visitor interface {
+	Visit(Node) Visitor
+}
+
+func Walk(n Node, v Visitor) {
+	if n == nil {
+		return
+	}
+	if v = v.Visit(n); v == nil {
+		return
+	}
+	for _, c := range children(n) {
+		Walk(c, v)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(n Node) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+func Inspect(n Node, fn func(Node) bool) {
+	Walk(n, inspector(fn))
+}
+
+func children(n Node) []Node {
+	var list []Node
+	if c := getComment(n); c != nil {
+		if _, ok := n.(*slice); !ok {
+			list = append(list, c)
+		}
+	}
+	switch n := n.(type) {
+	case *object:
+		list = append(list, n.Nodes...)
+		for _, fn := range n.Functions() {
+			var found bool
+			for _, c := range n.Nodes {
+				if found = c == fn; found {
+					break
+				}
+			}
+			if !found {
+				list = append(list, fn)
+			}
+		}
+	case *option:
+		if n.Value != nil {
+			list = append(list, n.Value)
+		}
+	case *array:
+		list = append(list, n.Nodes...)
+	case *template:
+		list = append(list, n.Nodes...)
+	case *slice:
+		list = append(list, n.Node)
+	case *call:
+		list = append(list, callChildren(n)...)
+	case *macro:
+		list = append(list, callChildren(n.call)...)
+		if n.Body != nil {
+			list = append(list, n.Body)
+		}
+	case *function:
+		for _, p := range n.Params {
+			if p.Default != nil {
+				list = append(list, p.Default)
+			}
+		}
+		list = append(list, n.Body)
+	case *unary:
+		list = append(list, n.Right)
+	case *binary:
+		list = append(list, n.Left, n.Right)
+	case *ternary:
+		list = append(list, n.Cond, n.Csq, n.Alt)
+	}
+	return list
+}
+
+func callChildren(c *call) []Node {
+	list := append([]Node{}, c.Args...)
+	for _, k := range c.Keys() {
+		list = append(list, c.Kwargs[k])
+	}
+	return list
+}
@@ -0,0 +1,44 @@
+package fig_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/midbel/fig"
+)
+
+func ExampleInspect() {
+	const demo = `
+name = "demo"
+server {
+  addr = "192.168.67.181"
+  port = upper($name)
+}
+	`
+	n, err := fig.Parse(strings.NewReader(demo))
+	if err != nil {
+		fmt.Printf("fail to parse document: %s\n", err)
+		return
+	}
+	fig.Inspect(n, func(n fig.Node) bool {
+		switch n := n.(type) {
+		case fig.ObjectNode:
+			fmt.Printf("%s: object %s\n", n.Pos(), n.Key())
+		case fig.OptionNode:
+			fmt.Printf("%s: option %s\n", n.Pos(), n.Key())
+		case fig.CallNode:
+			fmt.Printf("%s: call %s\n", n.Pos(), n.Func())
+		case fig.VariableNode:
+			fmt.Printf("%s: variable %s\n", n.Pos(), n.Name())
+		}
+		return true
+	})
+	// Output:
+	// 0:0: object root
+	// 2:1: option name
+	// 3:1: object server
+	// 4:3: option addr
+	// 5:3: option port
+	// 5:10: call upper
+	// 5:16: variable name
+}